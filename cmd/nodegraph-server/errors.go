@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/linkerd"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Error codes of the API, stable across releases.
+const (
+	errorCodeInvalidParameters = "invalid_parameters"
+	errorCodeTimeout           = "timeout"
+	errorCodeUpstream          = "upstream_failure"
+	errorCodeInternal          = "internal_error"
+)
+
+// apiError is the body of the responses to failed requests.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// writeError logs err and answers it with the status and code matching its
+// cause. The request context tells whether it timed out.
+func writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	log.Error(err)
+
+	status, body := classifyError(ctx, err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(body); err != nil {
+		log.Error(err)
+	}
+}
+
+func classifyError(ctx context.Context, err error) (int, apiError) {
+	switch {
+	case errors.Is(err, linkerd.ErrInvalidParameters), errors.Is(err, graph.ErrUnsupportedView):
+		return http.StatusBadRequest, apiError{
+			Code:    errorCodeInvalidParameters,
+			Message: err.Error(),
+			Hint:    "check the query parameters of the data source",
+		}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, apiError{
+			Code:    errorCodeTimeout,
+			Message: err.Error(),
+			Hint:    "shorten the time range or the depth, or raise server.timeout",
+		}
+	case errors.Is(err, linkerd.ErrSource):
+		return http.StatusBadGateway, apiError{
+			Code:    errorCodeUpstream,
+			Message: err.Error(),
+			Hint:    "check that the graph source, such as Prometheus, is reachable and healthy",
+		}
+	default:
+		return http.StatusInternalServerError, apiError{
+			Code:    errorCodeInternal,
+			Message: err.Error(),
+			Hint:    "see the server logs",
+		}
+	}
+}
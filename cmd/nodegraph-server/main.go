@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"linkerd-nodegraph/internal/config"
-	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"linkerd-nodegraph/internal/graph/source"
+	"linkerd-nodegraph/internal/graph/source/cache"
 	"linkerd-nodegraph/internal/linkerd"
+	"linkerd-nodegraph/internal/metrics"
 	"net/http"
 	"os"
 	"time"
@@ -35,13 +38,28 @@ func main() {
 	log.SetOutput(os.Stderr)
 	log.SetLevel(log.InfoLevel)
 
-	promConfig, err := config.Prometheus.Config()
+	graphSource, err := source.New(config)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	prom, err := prometheus.NewClient(*promConfig)
-	if err != nil {
+	if config.Cached() {
+		graphSource = cache.New(graphSource, cache.Config{
+			TTL:       config.Server.Cache.TTL,
+			MaxBytes:  config.Server.Cache.MaxBytes,
+			Alignment: config.Server.Cache.Alignment,
+			Timeout:   config.Server.Timeout,
+		})
+	}
+
+	stats := linkerd.Stats{
+		Source:       graphSource,
+		Quantiles:    config.Server.Quantiles,
+		MaxDepth:     config.Server.MaxDepth,
+		MaxTimeRange: config.Server.MaxTimeRange,
+	}
+
+	if _, err := stats.Spec(linkerd.Parameters{}); err != nil {
 		log.Fatal(err)
 	}
 
@@ -49,18 +67,9 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	http.HandleFunc("/api/graph/fields", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		err := json.NewEncoder(w).Encode(linkerd.GraphSpec)
-		if err != nil {
-			log.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
-
-			return
-		}
-	})
-
-	http.HandleFunc("/api/graph/data", data(linkerd.Stats{Server: prom}))
+	http.Handle("/api/graph/fields", metrics.InstrumentHandler("fields", fields(stats)))
+	http.Handle("/api/graph/data", metrics.InstrumentHandler("data", data(stats)))
+	http.Handle("/metrics", metrics.Handler())
 
 	err = http.ListenAndServe(config.Server.Addr, func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -78,31 +87,69 @@ func main() {
 	}
 }
 
-func data(stats linkerd.Stats) func(w http.ResponseWriter, r *http.Request) {
+func fields(stats linkerd.Stats) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var params linkerd.Parameters
 
 		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
 
 		err := decoder.Decode(&params, r.URL.Query())
+		if err != nil {
+			writeError(r.Context(), w, fmt.Errorf("%w: %s", linkerd.ErrInvalidParameters, err))
+
+			return
+		}
+
+		spec, err := stats.Spec(params)
+		if err != nil {
+			writeError(r.Context(), w, err)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(spec)
 		if err != nil {
 			log.Error(err)
 			w.WriteHeader(http.StatusInternalServerError)
 
 			return
 		}
+	}
+}
+
+func data(stats linkerd.Stats) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params linkerd.Parameters
+
+		decoder := schema.NewDecoder()
+
+		err := decoder.Decode(&params, r.URL.Query())
+		if err != nil {
+			writeError(r.Context(), w, fmt.Errorf("%w: %s", linkerd.ErrInvalidParameters, err))
+
+			return
+		}
 
 		ctx, cancel := context.WithTimeout(r.Context(), timeoutDataHandler)
 		defer cancel()
 
 		graph, err := stats.Graph(ctx, params)
 		if err != nil {
-			log.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			writeError(ctx, w, err)
 
 			return
 		}
 
+		for _, warning := range graph.Warnings {
+			log.Warn(warning)
+		}
+
+		metrics.GraphNodes.Observe(float64(len(graph.Nodes)))
+		metrics.GraphEdges.Observe(float64(len(graph.Edges)))
+
 		w.Header().Set("Content-Type", "application/json")
 
 		err = json.NewEncoder(w).Encode(graph)
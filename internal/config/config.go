@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"linkerd-nodegraph/internal/graph/source/viz"
 	"os"
+	"path/filepath"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -19,6 +21,8 @@ type (
 
 const (
 	PrometheusGraphSource GraphSource = "prometheus"
+	VizGraphSource        GraphSource = "linkerd-viz"
+	FileGraphSource       GraphSource = "file"
 
 	LogLevelDebug LogLevel = "debug"
 	LogLevelInfo  LogLevel = "info"
@@ -40,6 +44,52 @@ type HTTP struct {
 type Prometheus struct {
 	HTTP   HTTP   `yaml:"http"`
 	Labels string `yaml:"labels"`
+	// Record is a directory to record every Prometheus response to.
+	Record string `yaml:"record"`
+	// Replay is a directory of recorded responses served instead of querying
+	// Prometheus.
+	Replay string `yaml:"replay"`
+	// Instant makes every stat a single instant query over the whole time
+	// range instead of the average of a range query.
+	Instant bool `yaml:"instant"`
+	// ScrapeInterval is the scrape interval of Prometheus, which the rate
+	// window of range queries is derived from.
+	ScrapeInterval time.Duration `yaml:"scrapeInterval"`
+	// MaxPoints bounds the number of points per series of range queries,
+	// the step growing with the time range.
+	MaxPoints int `yaml:"maxPoints"`
+	// Clusters, when set, replaces HTTP and Labels by the Prometheus of
+	// every cluster, federated into a single graph.
+	Clusters []Cluster `yaml:"clusters"`
+	// Queries override the Go templates of the Prometheus queries.
+	Queries Queries `yaml:"queries"`
+}
+
+// Queries are Go templates rendering the Prometheus queries, the default ones
+// when empty. See prometheus.QueryData for the data they receive.
+type Queries struct {
+	SuccessRate string `yaml:"successRate"`
+	Latency     string `yaml:"latency"`
+	Volume      string `yaml:"volume"`
+	Edges       string `yaml:"edges"`
+}
+
+// Cluster describes the Prometheus of a cluster, named as in the
+// dst_target_cluster label of the traffic mirrored to it.
+type Cluster struct {
+	Name   string `yaml:"name"`
+	HTTP   HTTP   `yaml:"http"`
+	Labels string `yaml:"labels"`
+}
+
+// Viz describes the Linkerd Viz web API.
+type Viz struct {
+	HTTP HTTP `yaml:"http"`
+}
+
+// File describes the topology file served by the file graph source.
+type File struct {
+	Path string `yaml:"path"`
 }
 
 type Config struct {
@@ -47,11 +97,37 @@ type Config struct {
 	GraphSource GraphSource `yaml:"graphSource"`
 	LogLevel    LogLevel    `yaml:"logLevel"`
 	Prometheus  Prometheus  `yaml:"prometheus"`
+	Viz         Viz         `yaml:"viz"`
+	File        File        `yaml:"file"`
 }
 
 type Server struct {
 	Timeout time.Duration `yaml:"timeout"`
 	Addr    string        `yaml:"addr"`
+	Cache   Cache         `yaml:"cache"`
+	// Quantiles are the latency quantiles shown when requests do not choose
+	// any with the quantiles parameter.
+	Quantiles []float64 `yaml:"quantiles"`
+	// MaxDepth bounds the depth of requests, unbounded when zero.
+	MaxDepth int `yaml:"maxDepth"`
+	// MaxTimeRange bounds the time range of requests, unbounded when zero.
+	MaxTimeRange time.Duration `yaml:"maxTimeRange"`
+}
+
+// Cache describes the cache of built graph snapshots. A zero TTL disables it.
+type Cache struct {
+	TTL time.Duration `yaml:"ttl"`
+	// MaxBytes bounds the estimated memory used by the cached snapshots.
+	MaxBytes int `yaml:"maxBytes"`
+	// Alignment is the duration the time range of requests is rounded down
+	// to, so that requests issued a few seconds apart share a snapshot.
+	Alignment time.Duration `yaml:"alignment"`
+}
+
+// Cached tells whether the snapshots of the graph source are cached. The file
+// source is not, its file being read again as soon as it changes.
+func (c *Config) Cached() bool {
+	return c.Server.Cache.TTL > 0 && c.GraphSource != FileGraphSource
 }
 
 func Default() *Config {
@@ -61,6 +137,14 @@ func Default() *Config {
 		Server: Server{
 			Timeout: time.Minute,
 			Addr:    ":5001",
+			Cache: Cache{
+				TTL:       30 * time.Second,
+				MaxBytes:  64 << 20,
+				Alignment: 30 * time.Second,
+			},
+			Quantiles:    []float64{0.95},
+			MaxDepth:     10,
+			MaxTimeRange: 31 * 24 * time.Hour,
 		},
 		Prometheus: Prometheus{
 			HTTP: HTTP{
@@ -70,7 +154,21 @@ func Default() *Config {
 					InsecureSkipVerify: false,
 				},
 			},
-			Labels: "",
+			Labels:         "",
+			ScrapeInterval: prometheus.DefaultScrapeInterval,
+			MaxPoints:      prometheus.DefaultMaxPoints,
+		},
+		Viz: Viz{
+			HTTP: HTTP{
+				Addr:    "http://web.linkerd-viz.svc.cluster.local:8084",
+				Headers: map[string]string{},
+				TLSConfig: TLSConfig{
+					InsecureSkipVerify: false,
+				},
+			},
+		},
+		File: File{
+			Path: "./topology.yaml",
 		},
 	}
 }
@@ -101,12 +199,77 @@ func FromFile(path string) (*Config, error) {
 }
 
 func (c *Prometheus) Config() (*prometheus.Config, error) {
+	tlsConfig, err := c.HTTP.TLSConfig.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	return &prometheus.Config{
+		Address:   c.HTTP.Addr,
+		Labels:    c.Labels,
+		Headers:   c.HTTP.Headers,
+		TLSConfig: tlsConfig,
+		RecordDir: c.Record,
+		ReplayDir: c.Replay,
+		Instant:   c.Instant,
+		Queries:   prometheus.QueryTemplates(c.Queries),
+
+		ScrapeInterval: c.ScrapeInterval,
+		MaxPoints:      c.MaxPoints,
+	}, nil
+}
+
+// ClusterConfig returns the configuration of the Prometheus of cluster,
+// recording to and replaying from a directory per cluster.
+func (c *Prometheus) ClusterConfig(cluster Cluster) (*prometheus.Config, error) {
+	tlsConfig, err := cluster.HTTP.TLSConfig.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &prometheus.Config{
+		Address:   cluster.HTTP.Addr,
+		Labels:    cluster.Labels,
+		Headers:   cluster.HTTP.Headers,
+		TLSConfig: tlsConfig,
+		Instant:   c.Instant,
+		Queries:   prometheus.QueryTemplates(c.Queries),
+
+		ScrapeInterval: c.ScrapeInterval,
+		MaxPoints:      c.MaxPoints,
+	}
+
+	if c.Record != "" {
+		config.RecordDir = filepath.Join(c.Record, cluster.Name)
+	}
+
+	if c.Replay != "" {
+		config.ReplayDir = filepath.Join(c.Replay, cluster.Name)
+	}
+
+	return config, nil
+}
+
+func (c *Viz) Config() (*viz.Config, error) {
+	tlsConfig, err := c.HTTP.TLSConfig.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	return &viz.Config{
+		Address:   c.HTTP.Addr,
+		Headers:   c.HTTP.Headers,
+		TLSConfig: tlsConfig,
+	}, nil
+}
+
+func (c *TLSConfig) Config() (*tls.Config, error) {
 	tlsConfig := tls.Config{
-		InsecureSkipVerify: c.HTTP.TLSConfig.InsecureSkipVerify,
+		InsecureSkipVerify: c.InsecureSkipVerify,
 	}
 
-	if c.HTTP.TLSConfig.CAFile != "" {
-		caBytes, err := os.ReadFile(c.HTTP.TLSConfig.CAFile)
+	if c.CAFile != "" {
+		caBytes, err := os.ReadFile(c.CAFile)
 		if err != nil {
 			return nil, fmt.Errorf("could not open ca file: %w", err)
 		}
@@ -116,8 +279,8 @@ func (c *Prometheus) Config() (*prometheus.Config, error) {
 		tlsConfig.RootCAs = caCertPool
 	}
 
-	if c.HTTP.TLSConfig.CertFile != "" && c.HTTP.TLSConfig.KeyFile != "" {
-		certificate, err := tls.LoadX509KeyPair(c.HTTP.TLSConfig.CertFile, c.HTTP.TLSConfig.KeyFile)
+	if c.CertFile != "" && c.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
 		if err != nil {
 			return nil, fmt.Errorf("could not load certificate: %w", err)
 		}
@@ -125,10 +288,5 @@ func (c *Prometheus) Config() (*prometheus.Config, error) {
 		tlsConfig.Certificates = []tls.Certificate{certificate}
 	}
 
-	return &prometheus.Config{
-		Address:   c.HTTP.Addr,
-		Labels:    c.Labels,
-		Headers:   c.HTTP.Headers,
-		TLSConfig: &tlsConfig,
-	}, nil
+	return &tlsConfig, nil
 }
@@ -21,3 +21,15 @@ func TestConfig(t *testing.T) {
 		t.Fatalf("expected %v, got %v", config.Default(), cnf)
 	}
 }
+
+func TestConfigCached(t *testing.T) {
+	cnf := config.Default()
+	if !cnf.Cached() {
+		t.Fatal("expected the default config to be cached")
+	}
+
+	cnf.GraphSource = config.FileGraphSource
+	if cnf.Cached() {
+		t.Fatal("expected the file source not to be cached")
+	}
+}
@@ -7,12 +7,28 @@ type ResourceKind int
 const (
 	DeploymentKind ResourceKind = iota
 	StatefulsetKind
+	DaemonsetKind
+	CronjobKind
+	JobKind
+	ReplicasetKind
+	PodKind
+	UnmeshedKind
+	ExternalKind
+	ServiceKind
+	NamespaceKind
 	UndefinedKind
 )
 
+// ResourceKinds lists every known kind, from the most to the least specific
+// owner of a pod.
 var ResourceKinds = []ResourceKind{
 	DeploymentKind,
 	StatefulsetKind,
+	DaemonsetKind,
+	CronjobKind,
+	JobKind,
+	ReplicasetKind,
+	PodKind,
 }
 
 func (k ResourceKind) String() string {
@@ -21,6 +37,24 @@ func (k ResourceKind) String() string {
 		return "deployment"
 	case StatefulsetKind:
 		return "statefulset"
+	case DaemonsetKind:
+		return "daemonset"
+	case CronjobKind:
+		return "cronjob"
+	case JobKind:
+		return "job"
+	case ReplicasetKind:
+		return "replicaset"
+	case PodKind:
+		return "pod"
+	case UnmeshedKind:
+		return "unmeshed"
+	case ExternalKind:
+		return "external"
+	case ServiceKind:
+		return "service"
+	case NamespaceKind:
+		return "namespace"
 	case UndefinedKind:
 		fallthrough
 	default:
@@ -34,34 +68,189 @@ func ResourceKindFromString(k string) ResourceKind {
 		return DeploymentKind
 	case "statefulset":
 		return StatefulsetKind
+	case "daemonset":
+		return DaemonsetKind
+	case "cronjob":
+		return CronjobKind
+	case "job":
+		return JobKind
+	case "replicaset":
+		return ReplicasetKind
+	case "pod":
+		return PodKind
+	case "unmeshed":
+		return UnmeshedKind
+	case "external":
+		return ExternalKind
+	case "service":
+		return ServiceKind
+	case "namespace":
+		return NamespaceKind
 	default:
 		return UndefinedKind
 	}
 }
 
+// Synthetic reports whether k describes a destination only known from the
+// outbound traffic of its clients, such as an unmeshed service or an external
+// host.
+func (k ResourceKind) Synthetic() bool {
+	return k == UnmeshedKind || k == ExternalKind
+}
+
 type Resource struct {
 	Name      string
 	Namespace string
 	Kind      ResourceKind
+	// Cluster is the name of the cluster running the resource, empty when
+	// the graph covers a single cluster.
+	Cluster string
 }
 
+// DefaultQuantile is the latency quantile computed when none is asked for.
+const DefaultQuantile = 0.95
+
+// Latencies maps quantiles, such as 0.95, to latencies in milliseconds.
+// Quantiles missing or zero are unknown.
+type Latencies map[float64]float64
+
 type Node struct {
 	Resource Resource
 
-	SuccessRate   float64
-	LatencyP95    float64
-	RequestVolume float64
+	// SuccessRate is unknown when zero, unless SuccessRateKnown is set, such
+	// as when every request failed.
+	SuccessRate      float64
+	SuccessRateKnown bool
+	Latencies        Latencies
+	RequestVolume    float64
 }
 
 type Edge struct {
 	Source      *Node
 	Destination *Node
+
+	RequestRate float64
+	// SuccessRate is unknown when zero, unless SuccessRateKnown is set.
+	SuccessRate      float64
+	SuccessRateKnown bool
+	Latencies        Latencies
+}
+
+// HasSuccessRate reports whether the success rate of n is known.
+func (n Node) HasSuccessRate() bool {
+	return n.SuccessRateKnown || n.SuccessRate != 0
+}
+
+// HasSuccessRate reports whether the success rate of e is known.
+func (e Edge) HasSuccessRate() bool {
+	return e.SuccessRateKnown || e.SuccessRate != 0
 }
 
 func (n Node) ID() string {
-	return fmt.Sprintf("%s__%s__%s", n.Resource.Namespace, n.Resource.Name, n.Resource.Kind.String())
+	id := fmt.Sprintf("%s__%s__%s", n.Resource.Namespace, n.Resource.Name, n.Resource.Kind.String())
+	if n.Resource.Cluster != "" {
+		return n.Resource.Cluster + "__" + id
+	}
+
+	return id
 }
 
 func (e Edge) ID() string {
 	return fmt.Sprintf("%s__%s", e.Source.ID(), e.Destination.ID())
 }
+
+// Merge adds the traffic of other to n, averaging the success rate and
+// latency weighted by request volume.
+func (n *Node) Merge(other Node) {
+	n.SuccessRate, n.SuccessRateKnown = mergeSuccessRates(
+		n.SuccessRate, n.HasSuccessRate(), n.RequestVolume,
+		other.SuccessRate, other.HasSuccessRate(), other.RequestVolume,
+	)
+	n.Latencies = mergeLatencies(n.Latencies, n.RequestVolume, other.Latencies, other.RequestVolume)
+	n.RequestVolume += other.RequestVolume
+}
+
+// Merge adds the traffic of other to e, averaging the success rate and
+// latency weighted by request rate.
+func (e *Edge) Merge(other Edge) {
+	e.SuccessRate, e.SuccessRateKnown = mergeSuccessRates(
+		e.SuccessRate, e.HasSuccessRate(), e.RequestRate,
+		other.SuccessRate, other.HasSuccessRate(), other.RequestRate,
+	)
+	e.Latencies = mergeLatencies(e.Latencies, e.RequestRate, other.Latencies, other.RequestRate)
+	e.RequestRate += other.RequestRate
+}
+
+// MergeEdges merges the edges sharing the same ID, keeping the order in which
+// they were first seen.
+func MergeEdges(edges []Edge) []Edge {
+	merged := []Edge{}
+	index := map[string]int{}
+
+	for _, edge := range edges {
+		if i, ok := index[edge.ID()]; ok {
+			merged[i].Merge(edge)
+
+			continue
+		}
+
+		index[edge.ID()] = len(merged)
+		merged = append(merged, edge)
+	}
+
+	return merged
+}
+
+// mergeSuccessRates returns the success rates a and b averaged by their
+// weights, ignoring unknown ones, and whether the result is known.
+func mergeSuccessRates(
+	a float64, knownA bool, weightA float64, b float64, knownB bool, weightB float64,
+) (float64, bool) {
+	switch {
+	case !knownA:
+		return b, knownB
+	case !knownB:
+		return a, true
+	default:
+		return weightedMean(a, weightA, b, weightB), true
+	}
+}
+
+// mergeLatencies returns the latencies of a and b averaged by their weights,
+// quantile by quantile, ignoring unknown (zero) latencies.
+func mergeLatencies(a Latencies, weightA float64, b Latencies, weightB float64) Latencies {
+	if len(a) == 0 && len(b) == 0 {
+		return a
+	}
+
+	merged := Latencies{}
+
+	for quantile, latency := range a {
+		switch other := b[quantile]; {
+		case other == 0:
+			merged[quantile] = latency
+		case latency == 0:
+			merged[quantile] = other
+		default:
+			merged[quantile] = weightedMean(latency, weightA, other, weightB)
+		}
+	}
+
+	for quantile, latency := range b {
+		if _, ok := a[quantile]; !ok {
+			merged[quantile] = latency
+		}
+	}
+
+	return merged
+}
+
+// weightedMean averages a and b by their weights, evenly when both weights
+// are zero.
+func weightedMean(a float64, weightA float64, b float64, weightB float64) float64 {
+	if weightA+weightB == 0 {
+		return (a + b) / 2 //nolint:gomnd
+	}
+
+	return (a*weightA + b*weightB) / (weightA + weightB)
+}
@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// View selects what the nodes of a snapshot stand for.
+type View string
+
+const (
+	WorkloadView View = "workload"
+	ServiceView  View = "service"
+)
+
+var ErrUnsupportedView = errors.New("view not supported by the graph source")
+
+// Query describes the snapshot to build.
+type Query struct {
+	// From and To bound the time range, in milliseconds since epoch.
+	From int64
+	To   int64
+	View View
+	// Quantiles are the latency quantiles to compute, DefaultQuantile when
+	// empty.
+	Quantiles []float64
+	// RateWindow is the window rates are computed over, chosen by the source
+	// when zero.
+	RateWindow time.Duration
+	// Step is the resolution of the range queries of the source, chosen by
+	// the source when zero.
+	Step time.Duration
+}
+
+// Snapshot is a graph built from the metrics of a time range.
+type Snapshot interface {
+	// Node returns the node of resource, with empty stats if unknown.
+	Node(ctx context.Context, resource Resource) *Node
+	// Resources lists every resource of the snapshot.
+	Resources(ctx context.Context) []Resource
+	// UpstreamEdgesOf returns the edges going out of node.
+	UpstreamEdgesOf(ctx context.Context, node *Node) []Edge
+	// DownstreamEdgesOf returns the edges coming into node.
+	DownstreamEdgesOf(ctx context.Context, node *Node) []Edge
+	// EdgesOf returns the edges going out of and coming into node.
+	EdgesOf(ctx context.Context, node *Node) []Edge
+}
+
+// Source builds snapshots out of a metrics backend.
+type Source interface {
+	Snapshot(ctx context.Context, query Query) (Snapshot, error)
+}
+
+// Warner is implemented by the snapshots able to tell why some of their stats
+// may be missing, such as when some metrics could not be queried.
+type Warner interface {
+	Warnings() []string
+}
+
+// Warnings returns the warnings of snapshot, if it is a Warner.
+func Warnings(snapshot Snapshot) []string {
+	if warner, ok := snapshot.(Warner); ok {
+		return warner.Warnings()
+	}
+
+	return nil
+}
+
+// Member is a workload behind a Service.
+type Member struct {
+	Resource Resource
+	// Share is the part of the requests to the Service the workload received.
+	Share float64
+}
+
+// ServiceResolver is implemented by the snapshots able to tell the workloads
+// behind their Services, such as the targets of the Services mirrored by
+// other clusters.
+type ServiceResolver interface {
+	// Members returns the workloads behind service.
+	Members(ctx context.Context, service Resource) []Member
+	// Services returns the Services in front of workload.
+	Services(ctx context.Context, workload Resource) []Resource
+}
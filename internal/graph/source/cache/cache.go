@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/metrics"
+	"sync"
+	"time"
+)
+
+// errBuildAborted is the error of the shared builds that did not return,
+// such as when the source panicked.
+var errBuildAborted = errors.New("snapshot build aborted")
+
+// defaultSize is the size assumed for snapshots that do not report theirs.
+const defaultSize = 1 << 20
+
+// sizer is implemented by the snapshots able to estimate their memory
+// footprint, in bytes.
+type sizer interface {
+	Size() int
+}
+
+type Config struct {
+	// TTL is how long a snapshot is served from the cache.
+	TTL time.Duration
+	// MaxBytes bounds the estimated memory used by the cached snapshots.
+	MaxBytes int
+	// Alignment is the duration the time range of queries is aligned to, so
+	// that queries issued a few seconds apart share the same snapshot.
+	Alignment time.Duration
+	// Timeout bounds the builds, which do not end with the request that
+	// started them. Builds are unbounded when zero.
+	Timeout time.Duration
+}
+
+type entry struct {
+	key      string
+	snapshot graph.Snapshot
+	size     int
+	expires  time.Time
+}
+
+// call is a build in flight, shared by every identical query.
+type call struct {
+	done     chan struct{}
+	snapshot graph.Snapshot
+	err      error
+}
+
+// Source caches the snapshots built by another graph.Source. Concurrent
+// identical queries share a single build, and the least recently used
+// snapshots are evicted once the cache grows over its maximum size.
+type Source struct {
+	source graph.Source
+	config Config
+	now    func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	size     int
+	inFlight map[string]*call
+}
+
+func New(source graph.Source, config Config) *Source {
+	return &Source{
+		source:   source,
+		config:   config,
+		now:      time.Now,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		inFlight: map[string]*call{},
+	}
+}
+
+// Snapshot returns the cached snapshot of query, once aligned, or builds it.
+// Failed builds are not cached. Builds run detached from the context of the
+// caller, so that the callers sharing them do not fail with it, and callers
+// whose shared build failed on its own context retry it.
+func (s *Source) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	query = s.align(query)
+	key := fmt.Sprintf("%+v", query)
+
+	for {
+		snapshot, shared, err := s.snapshot(ctx, key, query)
+		if shared && isContextError(err) && ctx.Err() == nil {
+			continue
+		}
+
+		return snapshot, err
+	}
+}
+
+// snapshot returns the snapshot cached under key, waits for its build in
+// flight, or builds it. It reports whether the build was shared.
+func (s *Source) snapshot(ctx context.Context, key string, query graph.Query) (graph.Snapshot, bool, error) {
+	s.mu.Lock()
+
+	if snapshot, ok := s.get(key); ok {
+		s.mu.Unlock()
+		metrics.CacheRequests.WithLabelValues("hit").Inc()
+
+		return snapshot, false, nil
+	}
+
+	if c, ok := s.inFlight[key]; ok {
+		s.mu.Unlock()
+		metrics.CacheRequests.WithLabelValues("shared").Inc()
+
+		select {
+		case <-c.done:
+			return c.snapshot, true, c.err
+		case <-ctx.Done():
+			return nil, true, ctx.Err() //nolint:wrapcheck
+		}
+	}
+
+	c := &call{done: make(chan struct{}), err: errBuildAborted}
+	s.inFlight[key] = c
+	s.mu.Unlock()
+	metrics.CacheRequests.WithLabelValues("miss").Inc()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.inFlight, key)
+
+		if c.err == nil {
+			s.add(key, c.snapshot)
+		}
+
+		s.mu.Unlock()
+		close(c.done)
+	}()
+
+	buildCtx, cancel := s.buildContext()
+	defer cancel()
+
+	c.snapshot, c.err = s.source.Snapshot(buildCtx, query)
+
+	return c.snapshot, false, c.err
+}
+
+// buildContext returns the context builds run on, bounded by the configured
+// timeout only.
+func (s *Source) buildContext() (context.Context, context.CancelFunc) {
+	if s.config.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), s.config.Timeout)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// align rounds the time range of query down to the configured alignment.
+func (s *Source) align(query graph.Query) graph.Query {
+	alignment := s.config.Alignment.Milliseconds()
+	if alignment <= 0 {
+		return query
+	}
+
+	query.From -= query.From % alignment
+	query.To -= query.To % alignment
+
+	return query
+}
+
+// get returns the snapshot cached under key, if not expired. It must be
+// called with the lock held.
+func (s *Source) get(key string) (graph.Snapshot, bool) {
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := element.Value.(*entry) //nolint:forcetypeassert
+	if !s.now().Before(e.expires) {
+		s.remove(element)
+
+		return nil, false
+	}
+
+	s.lru.MoveToFront(element)
+
+	return e.snapshot, true
+}
+
+// add caches snapshot, evicting the least recently used snapshots to stay
+// under the maximum size. It must be called with the lock held.
+func (s *Source) add(key string, snapshot graph.Snapshot) {
+	if s.config.TTL <= 0 {
+		return
+	}
+
+	size := defaultSize
+	if sized, ok := snapshot.(sizer); ok {
+		size = sized.Size()
+	}
+
+	if s.config.MaxBytes > 0 && size > s.config.MaxBytes {
+		return
+	}
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+
+	s.entries[key] = s.lru.PushFront(&entry{
+		key:      key,
+		snapshot: snapshot,
+		size:     size,
+		expires:  s.now().Add(s.config.TTL),
+	})
+	s.size += size
+
+	for s.config.MaxBytes > 0 && s.size > s.config.MaxBytes {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *Source) remove(element *list.Element) {
+	e := s.lru.Remove(element).(*entry) //nolint:forcetypeassert
+	delete(s.entries, e.key)
+	s.size -= e.size
+}
@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/metrics"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSnapshot struct {
+	graph.Snapshot
+	size int
+}
+
+func (f fakeSnapshot) Size() int {
+	return f.size
+}
+
+var errBuild = errors.New("build failed")
+
+type fakeSource struct {
+	builds  int32
+	size    int
+	err     error
+	panics  bool
+	release chan struct{}
+}
+
+func (f *fakeSource) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	atomic.AddInt32(&f.builds, 1)
+
+	if f.release != nil {
+		<-f.release
+	}
+
+	if f.panics {
+		panic("build panicked")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return fakeSnapshot{size: f.size}, nil
+}
+
+func TestSourceCachesAlignedQueries(t *testing.T) {
+	now := time.Unix(0, 0)
+	source := &fakeSource{size: 10}
+	cache := New(source, Config{TTL: time.Minute, MaxBytes: 100, Alignment: 30 * time.Second})
+	cache.now = func() time.Time { return now }
+
+	query := graph.Query{From: 60000, To: 120000, View: graph.WorkloadView}
+
+	_, err := cache.Snapshot(context.Background(), query)
+	assert.Nil(t, err)
+
+	_, err = cache.Snapshot(context.Background(), graph.Query{From: 61000, To: 125000, View: graph.WorkloadView})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), source.builds)
+
+	_, err = cache.Snapshot(context.Background(), graph.Query{From: 60000, To: 120000, View: graph.ServiceView})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), source.builds)
+
+	now = now.Add(time.Minute)
+
+	_, err = cache.Snapshot(context.Background(), query)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), source.builds)
+}
+
+func TestSourceEvictsLeastRecentlyUsed(t *testing.T) {
+	source := &fakeSource{size: 40}
+	cache := New(source, Config{TTL: time.Minute, MaxBytes: 100})
+
+	queries := []graph.Query{{From: 1}, {From: 2}, {From: 3}}
+
+	for _, query := range queries[:2] {
+		_, _ = cache.Snapshot(context.Background(), query)
+	}
+
+	_, _ = cache.Snapshot(context.Background(), queries[0])
+	_, _ = cache.Snapshot(context.Background(), queries[2])
+
+	assert.Equal(t, int32(3), source.builds)
+	assert.Equal(t, 80, cache.size)
+
+	_, _ = cache.Snapshot(context.Background(), queries[0])
+	assert.Equal(t, int32(3), source.builds)
+
+	_, _ = cache.Snapshot(context.Background(), queries[1])
+	assert.Equal(t, int32(4), source.builds)
+}
+
+func TestSourceDoesNotCacheFailures(t *testing.T) {
+	source := &fakeSource{err: errBuild}
+	cache := New(source, Config{TTL: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.Snapshot(context.Background(), graph.Query{})
+		assert.True(t, errors.Is(err, errBuild))
+	}
+
+	assert.Equal(t, int32(2), source.builds)
+}
+
+func TestSourceSharesConcurrentBuilds(t *testing.T) {
+	source := &fakeSource{release: make(chan struct{})}
+	cache := New(source, Config{TTL: time.Minute})
+
+	var wg sync.WaitGroup
+
+	snapshots := make([]graph.Snapshot, 10)
+
+	for i := range snapshots {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			snapshots[i], _ = cache.Snapshot(context.Background(), graph.Query{})
+		}(i)
+	}
+
+	for atomic.LoadInt32(&source.builds) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(source.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), source.builds)
+
+	for _, snapshot := range snapshots {
+		assert.NotNil(t, snapshot)
+	}
+}
+
+func TestSourceSharesBuildsCanceledByTheirLeader(t *testing.T) {
+	source := &fakeSource{release: make(chan struct{})}
+	cache := New(source, Config{TTL: time.Minute})
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+
+	var leader, waiter graph.Snapshot
+
+	var leaderErr, waiterErr error
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		leader, leaderErr = cache.Snapshot(leaderCtx, graph.Query{})
+	}()
+
+	for atomic.LoadInt32(&source.builds) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	go func() {
+		defer wg.Done()
+
+		waiter, waiterErr = cache.Snapshot(context.Background(), graph.Query{})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	close(source.release)
+	wg.Wait()
+
+	assert.Nil(t, leaderErr)
+	assert.NotNil(t, leader)
+	assert.Nil(t, waiterErr)
+	assert.NotNil(t, waiter)
+	assert.Equal(t, int32(1), source.builds)
+}
+
+func TestSourceRecoversFromPanickingBuilds(t *testing.T) {
+	source := &fakeSource{panics: true}
+	cache := New(source, Config{TTL: time.Minute})
+
+	assert.Panics(t, func() {
+		_, _ = cache.Snapshot(context.Background(), graph.Query{})
+	})
+
+	source.panics = false
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	snapshot, err := cache.Snapshot(ctx, graph.Query{})
+	assert.Nil(t, err)
+	assert.NotNil(t, snapshot)
+}
+
+func TestSourceCountsRequests(t *testing.T) {
+	count := func(result string) float64 {
+		return testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(result))
+	}
+	hits, misses := count("hit"), count("miss")
+
+	cache := New(&fakeSource{}, Config{TTL: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.Snapshot(context.Background(), graph.Query{From: 1})
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, 2.0, count("hit")-hits)
+	assert.Equal(t, 1.0, count("miss")-misses)
+}
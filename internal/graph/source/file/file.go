@@ -0,0 +1,216 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var ErrInvalidTopology = errors.New("invalid topology")
+
+// resource identifies a node of the topology. Kind defaults to deployment.
+type resource struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+	Kind      string `yaml:"kind"`
+	Cluster   string `yaml:"cluster"`
+}
+
+// latencies are the latencies of a node or an edge. LatencyP95 is a shorthand
+// for the 0.95 quantile of Latencies, keyed by quantile.
+type latencies struct {
+	LatencyP95 float64            `yaml:"latencyP95"`
+	Latencies  map[string]float64 `yaml:"latencies"`
+}
+
+type node struct {
+	resource      `yaml:",inline"`
+	latencies     `yaml:",inline"`
+	SuccessRate   *float64 `yaml:"successRate"`
+	RequestVolume float64  `yaml:"requestVolume"`
+}
+
+type edge struct {
+	latencies   `yaml:",inline"`
+	Source      resource `yaml:"source"`
+	Destination resource `yaml:"destination"`
+	RequestRate float64  `yaml:"requestRate"`
+	SuccessRate *float64 `yaml:"successRate"`
+}
+
+// topology is the content of a topology file, in YAML or JSON.
+type topology struct {
+	Nodes []node `yaml:"nodes"`
+	Edges []edge `yaml:"edges"`
+}
+
+// Source serves the graph described by a topology file, whatever the time
+// range and view of the query. The file is read again whenever its
+// modification time or size changes.
+type Source struct {
+	path string
+
+	mu       sync.Mutex
+	modTime  time.Time
+	size     int64
+	snapshot *Snapshot
+}
+
+func NewSource(path string) *Source {
+	return &Source{path: path}
+}
+
+// Snapshot returns the topology of the file, read again when it changed. The
+// topology is made of workloads, so the service view is not supported.
+func (s *Source) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	if query.View != graph.WorkloadView {
+		return nil, fmt.Errorf("%w: %q", graph.ErrUnsupportedView, query.View)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading topology file: %w", err)
+	}
+
+	if s.snapshot != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.snapshot, nil
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading topology file: %w", err)
+	}
+
+	snapshot, err := parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing topology file %s: %w", s.path, err)
+	}
+
+	s.snapshot = snapshot
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+
+	return snapshot, nil
+}
+
+// parse returns the snapshot of the topology in content.
+func parse(content []byte) (*Snapshot, error) {
+	var t topology
+
+	if err := yaml.UnmarshalStrict(content, &t); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTopology, err.Error())
+	}
+
+	snapshot := newSnapshot()
+
+	for _, n := range t.Nodes {
+		r, err := n.graphResource()
+		if err != nil {
+			return nil, err
+		}
+
+		latencies, err := n.graphLatencies()
+		if err != nil {
+			return nil, err
+		}
+
+		successRate, known := successRate(n.SuccessRate)
+
+		snapshot.addResource(r)
+		snapshot.nodes[r] = graph.Node{
+			Resource:         r,
+			SuccessRate:      successRate,
+			SuccessRateKnown: known,
+			Latencies:        latencies,
+			RequestVolume:    n.RequestVolume,
+		}
+	}
+
+	for _, e := range t.Edges {
+		source, err := e.Source.graphResource()
+		if err != nil {
+			return nil, fmt.Errorf("invalid edge source: %w", err)
+		}
+
+		destination, err := e.Destination.graphResource()
+		if err != nil {
+			return nil, fmt.Errorf("invalid edge destination: %w", err)
+		}
+
+		latencies, err := e.graphLatencies()
+		if err != nil {
+			return nil, err
+		}
+
+		successRate, known := successRate(e.SuccessRate)
+
+		snapshot.addResource(source)
+		snapshot.addResource(destination)
+		snapshot.edges = append(snapshot.edges, snapshotEdge{
+			source:           source,
+			destination:      destination,
+			requestRate:      e.RequestRate,
+			successRate:      successRate,
+			successRateKnown: known,
+			latencies:        latencies,
+		})
+	}
+
+	return snapshot, nil
+}
+
+// successRate returns the success rate given by a topology, and whether it
+// was given at all.
+func successRate(rate *float64) (float64, bool) {
+	if rate == nil {
+		return 0, false
+	}
+
+	return *rate, true
+}
+
+func (l latencies) graphLatencies() (graph.Latencies, error) {
+	latencies := graph.Latencies{}
+
+	if l.LatencyP95 != 0 {
+		latencies[graph.DefaultQuantile] = l.LatencyP95
+	}
+
+	for key, latency := range l.Latencies {
+		quantile, err := strconv.ParseFloat(key, 64)
+		if err != nil || quantile <= 0 || quantile >= 1 {
+			return nil, fmt.Errorf("%w: invalid quantile %q", ErrInvalidTopology, key)
+		}
+
+		latencies[quantile] = latency
+	}
+
+	return latencies, nil
+}
+
+func (r resource) graphResource() (graph.Resource, error) {
+	if r.Name == "" {
+		return graph.Resource{}, fmt.Errorf("%w: resource without a name", ErrInvalidTopology)
+	}
+
+	kind := graph.DeploymentKind
+
+	if r.Kind != "" {
+		kind = graph.ResourceKindFromString(r.Kind)
+		if kind == graph.UndefinedKind {
+			return graph.Resource{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidTopology, r.Kind)
+		}
+	}
+
+	return graph.Resource{Name: r.Name, Namespace: r.Namespace, Kind: kind, Cluster: r.Cluster}, nil
+}
@@ -0,0 +1,164 @@
+package file_test
+
+import (
+	"context"
+	"errors"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source/file"
+	"linkerd-nodegraph/internal/linkerd"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const topology = `
+nodes:
+  - name: web
+    namespace: front
+    successRate: 1
+    latencyP95: 10
+    requestVolume: 5
+  - name: api
+    namespace: back
+    kind: statefulset
+    successRate: 0.5
+edges:
+  - source: {name: web, namespace: front}
+    destination: {name: api, namespace: back, kind: statefulset}
+    requestRate: 2
+    successRate: 0.9
+    latencyP95: 30
+    latencies: {"0.5": 4, 0.99: 60}
+  - source: {name: api, namespace: back, kind: statefulset}
+    destination: {name: db, namespace: data}
+    requestRate: 1
+`
+
+func writeTopology(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSourceSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.yaml")
+	writeTopology(t, path, topology)
+
+	source := file.NewSource(path)
+
+	snapshot, err := source.Snapshot(context.Background(), graph.Query{View: graph.WorkloadView})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := graph.Resource{Name: "web", Namespace: "front", Kind: graph.DeploymentKind}
+	api := graph.Resource{Name: "api", Namespace: "back", Kind: graph.StatefulsetKind}
+	db := graph.Resource{Name: "db", Namespace: "data", Kind: graph.DeploymentKind}
+
+	assert.Equal(t, []graph.Resource{web, api, db}, snapshot.Resources(context.Background()))
+	assert.Equal(t,
+		&graph.Node{Resource: web, SuccessRate: 1, SuccessRateKnown: true, Latencies: graph.Latencies{0.95: 10}, RequestVolume: 5},
+		snapshot.Node(context.Background(), web),
+	)
+
+	edges := snapshot.UpstreamEdgesOf(context.Background(), snapshot.Node(context.Background(), web))
+	assert.Len(t, edges, 1)
+	assert.Equal(t, api, edges[0].Destination.Resource)
+	assert.Equal(t, 0.5, edges[0].Destination.SuccessRate)
+	assert.Equal(t, 2.0, edges[0].RequestRate)
+	assert.Equal(t, 0.9, edges[0].SuccessRate)
+	assert.Equal(t, graph.Latencies{0.5: 4, 0.95: 30, 0.99: 60}, edges[0].Latencies)
+
+	assert.Len(t, snapshot.EdgesOf(context.Background(), snapshot.Node(context.Background(), api)), 2)
+}
+
+func TestSourceReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.json")
+	writeTopology(t, path, `{"nodes": [{"name": "web", "namespace": "front"}]}`)
+
+	source := file.NewSource(path)
+
+	snapshot, err := source.Snapshot(context.Background(), graph.Query{View: graph.WorkloadView})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, snapshot.Resources(context.Background()), 1)
+
+	writeTopology(t, path, `{"nodes": [{"name": "web", "namespace": "front"}, {"name": "api", "namespace": "back"}]}`)
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err = source.Snapshot(context.Background(), graph.Query{View: graph.WorkloadView})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, snapshot.Resources(context.Background()), 2)
+
+	writeTopology(t, path, `{"nodes": [{"name": "web", "kind": "foo"}]}`)
+
+	_, err = source.Snapshot(context.Background(), graph.Query{View: graph.WorkloadView})
+	assert.True(t, errors.Is(err, file.ErrInvalidTopology))
+
+	writeTopology(t, path, `{"nodes": [{"name": "web", "latencies": {"95": 10}}]}`)
+
+	_, err = source.Snapshot(context.Background(), graph.Query{View: graph.WorkloadView})
+	assert.True(t, errors.Is(err, file.ErrInvalidTopology))
+}
+
+func TestSourceStatsGraph(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.yaml")
+	writeTopology(t, path, topology)
+
+	stats := linkerd.Stats{Source: file.NewSource(path)}
+
+	tests := []struct {
+		name       string
+		parameters linkerd.Parameters
+		nodes      int
+		edges      int
+	}{
+		{
+			name:       "depth 1",
+			parameters: linkerd.Parameters{Roots: []string{"back/statefulset/api"}},
+			nodes:      3,
+			edges:      2,
+		},
+		{
+			name:       "outbound",
+			parameters: linkerd.Parameters{Roots: []string{"front/web"}, Direction: "outbound", Depth: 2},
+			nodes:      3,
+			edges:      2,
+		},
+		{
+			name:       "inbound",
+			parameters: linkerd.Parameters{Roots: []string{"data/db"}, Direction: "inbound"},
+			nodes:      2,
+			edges:      1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := stats.Graph(context.Background(), test.parameters)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Len(t, g.Nodes, test.nodes)
+			assert.Len(t, g.Edges, test.edges)
+		})
+	}
+
+	_, err := stats.Graph(context.Background(), linkerd.Parameters{Roots: []string{"front/web"}, View: "service"})
+	assert.True(t, errors.Is(err, graph.ErrUnsupportedView), err)
+}
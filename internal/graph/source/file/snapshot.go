@@ -0,0 +1,109 @@
+package file
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+)
+
+type snapshotEdge struct {
+	source           graph.Resource
+	destination      graph.Resource
+	requestRate      float64
+	successRate      float64
+	successRateKnown bool
+	latencies        graph.Latencies
+}
+
+// Snapshot is the graph.Snapshot of a topology file.
+type Snapshot struct {
+	nodes     map[graph.Resource]graph.Node
+	resources []graph.Resource
+	edges     []snapshotEdge
+}
+
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		nodes:     map[graph.Resource]graph.Node{},
+		resources: []graph.Resource{},
+		edges:     []snapshotEdge{},
+	}
+}
+
+func (s *Snapshot) addResource(resource graph.Resource) {
+	if _, ok := s.nodes[resource]; ok {
+		return
+	}
+
+	s.nodes[resource] = graph.Node{Resource: resource}
+	s.resources = append(s.resources, resource)
+}
+
+// Node returns the graph.Node associated with resource.
+func (s *Snapshot) Node(ctx context.Context, resource graph.Resource) *graph.Node {
+	node, ok := s.nodes[withKind(resource)]
+	if !ok {
+		return &graph.Node{Resource: resource}
+	}
+
+	node.Resource = resource
+
+	return &node
+}
+
+// withKind returns resource with its kind defaulting to deployment, as in
+// the topology file.
+func withKind(resource graph.Resource) graph.Resource {
+	if resource.Kind == graph.UndefinedKind {
+		resource.Kind = graph.DeploymentKind
+	}
+
+	return resource
+}
+
+// Resources returns the resources of the nodes and edges, in file order.
+func (s *Snapshot) Resources(ctx context.Context) []graph.Resource {
+	return append([]graph.Resource{}, s.resources...)
+}
+
+func (s *Snapshot) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, e := range s.edges {
+		if e.source == withKind(node.Resource) {
+			edges = append(edges, e.edge(node, s.Node(ctx, e.destination)))
+		}
+	}
+
+	return edges
+}
+
+func (s *Snapshot) DownstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, e := range s.edges {
+		if e.destination == withKind(node.Resource) {
+			edges = append(edges, e.edge(s.Node(ctx, e.source), node))
+		}
+	}
+
+	return edges
+}
+
+func (s *Snapshot) EdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+	edges = append(edges, s.UpstreamEdgesOf(ctx, node)...)
+	edges = append(edges, s.DownstreamEdgesOf(ctx, node)...)
+
+	return edges
+}
+
+func (e snapshotEdge) edge(source *graph.Node, destination *graph.Node) graph.Edge {
+	return graph.Edge{
+		Source:           source,
+		Destination:      destination,
+		RequestRate:      e.requestRate,
+		SuccessRate:      e.successRate,
+		SuccessRateKnown: e.successRateKnown,
+		Latencies:        e.latencies,
+	}
+}
@@ -0,0 +1,280 @@
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"sync"
+)
+
+// Cluster is a graph.Source covering a single cluster.
+type Cluster struct {
+	Name   string
+	Source graph.Source
+}
+
+// Source federates the snapshots of several clusters into a single graph.
+// Resources are given the name of the cluster running them, and the traffic
+// sent to services mirrored from another cluster, which single cluster
+// sources name after the target cluster, joins the graph of that cluster:
+// it goes to the workloads behind the service when the snapshot of the target
+// cluster is a graph.ServiceResolver, and to the service otherwise.
+// Resources without a cluster are not part of the federated graph.
+type Source struct {
+	Clusters []Cluster
+}
+
+func NewSource(clusters ...Cluster) *Source {
+	return &Source{Clusters: clusters}
+}
+
+// Snapshot builds the snapshot of every cluster concurrently. It fails if
+// any of them fails.
+func (s *Source) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	snapshots := make([]graph.Snapshot, len(s.Clusters))
+	errs := make([]error, len(s.Clusters))
+
+	var wg sync.WaitGroup
+
+	for i, cluster := range s.Clusters {
+		wg.Add(1)
+
+		go func(i int, cluster Cluster) {
+			defer wg.Done()
+
+			snapshots[i], errs[i] = cluster.Source.Snapshot(ctx, query)
+		}(i, cluster)
+	}
+
+	wg.Wait()
+
+	snapshot := &Snapshot{
+		clusters: []string{},
+		byName:   map[string]graph.Snapshot{},
+	}
+
+	for i, cluster := range s.Clusters {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.Name, errs[i])
+		}
+
+		snapshot.clusters = append(snapshot.clusters, cluster.Name)
+		snapshot.byName[cluster.Name] = snapshots[i]
+	}
+
+	return snapshot, nil
+}
+
+// Snapshot is the graph.Snapshot of several clusters.
+type Snapshot struct {
+	clusters []string
+	byName   map[string]graph.Snapshot
+}
+
+// global returns resource seen from cluster, naming the cluster running it.
+func global(cluster string, resource graph.Resource) graph.Resource {
+	if resource.Cluster == "" {
+		resource.Cluster = cluster
+	}
+
+	return resource
+}
+
+// local returns resource seen from the cluster running it.
+func local(resource graph.Resource) graph.Resource {
+	resource.Cluster = ""
+
+	return resource
+}
+
+// Warnings returns the warnings of every cluster, prefixed by its name.
+func (s *Snapshot) Warnings() []string {
+	warnings := []string{}
+
+	for _, cluster := range s.clusters {
+		for _, warning := range graph.Warnings(s.byName[cluster]) {
+			warnings = append(warnings, fmt.Sprintf("cluster %s: %s", cluster, warning))
+		}
+	}
+
+	return warnings
+}
+
+func (s *Snapshot) Resources(ctx context.Context) []graph.Resource {
+	resources := []graph.Resource{}
+	seen := map[graph.Resource]bool{}
+
+	for _, cluster := range s.clusters {
+		for _, resource := range s.byName[cluster].Resources(ctx) {
+			resource = global(cluster, resource)
+			if seen[resource] {
+				continue
+			}
+
+			seen[resource] = true
+			resources = append(resources, resource)
+		}
+	}
+
+	return resources
+}
+
+// Node returns the graph.Node of resource from the cluster running it. When
+// that cluster knows no traffic to resource, or is not federated, its stats
+// are taken from the clients of the other clusters.
+func (s *Snapshot) Node(ctx context.Context, resource graph.Resource) *graph.Node {
+	node := &graph.Node{Resource: resource}
+
+	if owner, ok := s.byName[resource.Cluster]; ok {
+		node = owner.Node(ctx, local(resource))
+		node.Resource = resource
+	}
+
+	if node.RequestVolume != 0 || resource.Cluster == "" {
+		return node
+	}
+
+	for _, cluster := range s.clusters {
+		if cluster != resource.Cluster {
+			node.Merge(*s.byName[cluster].Node(ctx, resource))
+		}
+	}
+
+	return node
+}
+
+// UpstreamEdgesOf returns the edges going out of node, as seen by the cluster
+// running it. The requests sent to a mirrored service are split between the
+// workloads behind it.
+func (s *Snapshot) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	owner, ok := s.byName[node.Resource.Cluster]
+	if !ok {
+		return edges
+	}
+
+	for _, edge := range owner.UpstreamEdgesOf(ctx, s.localNode(node)) {
+		edge.Source = node
+		destination := global(node.Resource.Cluster, edge.Destination.Resource)
+
+		members := s.mirrorMembers(ctx, node.Resource.Cluster, destination)
+		if len(members) == 0 {
+			edge.Destination = s.Node(ctx, destination)
+			edges = append(edges, edge)
+
+			continue
+		}
+
+		for _, member := range members {
+			memberEdge := edge
+			memberEdge.Destination = s.Node(ctx, member.Resource)
+			memberEdge.RequestRate *= member.Share
+			edges = append(edges, memberEdge)
+		}
+	}
+
+	return graph.MergeEdges(edges)
+}
+
+// DownstreamEdgesOf returns the edges coming into node from its own cluster
+// and from the other clusters.
+func (s *Snapshot) DownstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	if node.Resource.Cluster == "" {
+		return edges
+	}
+
+	for _, cluster := range s.clusters {
+		remote := *node
+		if cluster == node.Resource.Cluster {
+			remote = *s.localNode(node)
+		}
+
+		for _, edge := range s.byName[cluster].DownstreamEdgesOf(ctx, &remote) {
+			edge.Source = s.Node(ctx, global(cluster, edge.Source.Resource))
+			edge.Destination = node
+			edges = append(edges, edge)
+		}
+	}
+
+	edges = append(edges, s.mirrorClientEdges(ctx, node)...)
+
+	return graph.MergeEdges(edges)
+}
+
+// mirrorMembers returns the workloads behind resource, seen from cluster,
+// when it is a service mirrored from another cluster whose snapshot is a
+// graph.ServiceResolver.
+func (s *Snapshot) mirrorMembers(ctx context.Context, cluster string, resource graph.Resource) []graph.Member {
+	if resource.Kind != graph.ServiceKind || resource.Cluster == cluster {
+		return nil
+	}
+
+	resolver, ok := s.byName[resource.Cluster].(graph.ServiceResolver)
+	if !ok {
+		return nil
+	}
+
+	members := []graph.Member{}
+	for _, member := range resolver.Members(ctx, local(resource)) {
+		member.Resource = global(resource.Cluster, member.Resource)
+		members = append(members, member)
+	}
+
+	return members
+}
+
+// mirrorClientEdges returns the edges coming into the workload of node from
+// the clients of the other clusters through the services in front of it,
+// with its share of their requests.
+func (s *Snapshot) mirrorClientEdges(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	resolver, ok := s.byName[node.Resource.Cluster].(graph.ServiceResolver)
+	if !ok || node.Resource.Kind == graph.ServiceKind {
+		return edges
+	}
+
+	for _, service := range resolver.Services(ctx, local(node.Resource)) {
+		service = global(node.Resource.Cluster, service)
+
+		share := 0.0
+		for _, member := range s.mirrorMembers(ctx, "", service) {
+			if member.Resource == node.Resource {
+				share = member.Share
+			}
+		}
+
+		for _, cluster := range s.clusters {
+			if cluster == node.Resource.Cluster {
+				continue
+			}
+
+			for _, edge := range s.byName[cluster].DownstreamEdgesOf(ctx, &graph.Node{Resource: service}) {
+				edge.Source = s.Node(ctx, global(cluster, edge.Source.Resource))
+				edge.Destination = node
+				edge.RequestRate *= share
+				edges = append(edges, edge)
+			}
+		}
+	}
+
+	return edges
+}
+
+func (s *Snapshot) EdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+	edges = append(edges, s.UpstreamEdgesOf(ctx, node)...)
+	edges = append(edges, s.DownstreamEdgesOf(ctx, node)...)
+
+	return edges
+}
+
+func (s *Snapshot) localNode(node *graph.Node) *graph.Node {
+	localNode := *node
+	localNode.Resource = local(node.Resource)
+
+	return &localNode
+}
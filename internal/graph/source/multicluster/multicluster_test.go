@@ -0,0 +1,287 @@
+package multicluster_test
+
+import (
+	"context"
+	"errors"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source/file"
+	"linkerd-nodegraph/internal/graph/source/multicluster"
+	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"linkerd-nodegraph/internal/linkerd"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const eastTopology = `
+nodes:
+  - {name: web, namespace: front, requestVolume: 10}
+  - {name: db, namespace: data, cluster: north, requestVolume: 2}
+edges:
+  - source: {name: web, namespace: front}
+    destination: {name: api, namespace: back, kind: service, cluster: west}
+    requestRate: 4
+  - source: {name: web, namespace: front}
+    destination: {name: db, namespace: data, cluster: north}
+    requestRate: 2
+`
+
+const westTopology = `
+nodes:
+  - {name: api, namespace: back, kind: service, requestVolume: 7}
+edges:
+  - source: {name: linkerd-gateway, namespace: linkerd-multicluster}
+    destination: {name: api, namespace: back, kind: service}
+    requestRate: 4
+  - source: {name: cron, namespace: back}
+    destination: {name: api, namespace: back, kind: service}
+    requestRate: 3
+`
+
+// Groupings of the queries of the Prometheus workload builder.
+const (
+	byWorkload    = "namespace, workload_kind, workload_name"
+	byEdge        = "namespace, workload_kind, workload_name, dst_namespace, dst_workload_kind, dst_workload_name, dst_target_cluster"
+	byDestination = "dst_namespace, dst_workload_kind, dst_workload_name, dst_target_cluster"
+	byMember      = "dst_namespace, dst_service, dst_workload_kind, dst_workload_name"
+)
+
+var sumBy = regexp.MustCompile(`sum by \(([^)]*)\)`)
+
+// fakeAPI answers the request rate queries with the series of their
+// grouping, and the success rate and latency queries with nothing.
+type fakeAPI map[string]model.Matrix
+
+func (f fakeAPI) QueryRange(
+	ctx context.Context, query string, r prom.Range, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	if strings.Contains(query, "classification") || strings.Contains(query, "histogram_quantile") {
+		return model.Matrix{}, nil, nil
+	}
+
+	return f[sumBy.FindStringSubmatch(query)[1]], nil, nil
+}
+
+func (f fakeAPI) Query(
+	ctx context.Context, query string, ts time.Time, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	return model.Vector{}, nil, nil
+}
+
+// series returns the series of value with labels, given as name value pairs.
+func series(value float64, labels ...string) *model.SampleStream {
+	metric := model.Metric{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		metric[model.LabelName(labels[i])] = model.LabelValue(labels[i+1])
+	}
+
+	return &model.SampleStream{Metric: metric, Values: []model.SamplePair{{Value: model.SampleValue(value)}}}
+}
+
+type failingSource struct{}
+
+var errFailing = errors.New("failing")
+
+func (failingSource) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	return nil, errFailing
+}
+
+func fileSource(t *testing.T, topology string) *file.Source {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "topology.yaml")
+	if err := os.WriteFile(path, []byte(topology), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file.NewSource(path)
+}
+
+func newSource(t *testing.T) *multicluster.Source {
+	t.Helper()
+
+	return multicluster.NewSource(
+		multicluster.Cluster{Name: "east", Source: fileSource(t, eastTopology)},
+		multicluster.Cluster{Name: "west", Source: fileSource(t, westTopology)},
+	)
+}
+
+func TestSnapshot(t *testing.T) {
+	snapshot, err := newSource(t).Snapshot(context.Background(), graph.Query{View: graph.WorkloadView})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := graph.Resource{Name: "web", Namespace: "front", Kind: graph.DeploymentKind, Cluster: "east"}
+	api := graph.Resource{Name: "api", Namespace: "back", Kind: graph.ServiceKind, Cluster: "west"}
+	db := graph.Resource{Name: "db", Namespace: "data", Kind: graph.DeploymentKind, Cluster: "north"}
+
+	assert.Len(t, snapshot.Resources(context.Background()), 5)
+
+	edges := snapshot.UpstreamEdgesOf(context.Background(), snapshot.Node(context.Background(), web))
+	if assert.Len(t, edges, 2) {
+		assert.Equal(t, "east__front__web__deployment__west__back__api__service", edges[0].ID())
+		assert.Equal(t, 7.0, edges[0].Destination.RequestVolume)
+		assert.Equal(t, db, edges[1].Destination.Resource)
+		assert.Equal(t, 2.0, edges[1].Destination.RequestVolume)
+	}
+
+	edges = snapshot.DownstreamEdgesOf(context.Background(), snapshot.Node(context.Background(), api))
+	ids := []string{}
+
+	for _, edge := range edges {
+		ids = append(ids, edge.Source.ID())
+	}
+
+	assert.ElementsMatch(t, []string{
+		"west__linkerd-multicluster__linkerd-gateway__deployment",
+		"west__back__cron__deployment",
+		"east__front__web__deployment",
+	}, ids)
+
+	assert.Len(t, snapshot.DownstreamEdgesOf(context.Background(), &graph.Node{Resource: graph.Resource{
+		Name: "api", Namespace: "back", Kind: graph.ServiceKind,
+	}}), 0)
+}
+
+func TestSnapshotFails(t *testing.T) {
+	source := multicluster.NewSource(
+		multicluster.Cluster{Name: "east", Source: fileSource(t, eastTopology)},
+		multicluster.Cluster{Name: "west", Source: failingSource{}},
+	)
+
+	_, err := source.Snapshot(context.Background(), graph.Query{View: graph.WorkloadView})
+	assert.True(t, errors.Is(err, errFailing))
+}
+
+func TestStatsGraph(t *testing.T) {
+	stats := linkerd.Stats{Source: newSource(t)}
+
+	g, err := stats.Graph(context.Background(), linkerd.Parameters{
+		Roots: []string{"east/front/deployment/web"},
+		Depth: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, g.Nodes, 5)
+	assert.Len(t, g.Edges, 4)
+
+	g, err = stats.Graph(context.Background(), linkerd.Parameters{
+		Cluster:   "west",
+		Namespace: "back",
+		Name:      "api",
+		Kind:      "service",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, g.Nodes, 4)
+	assert.Equal(t, "west/back/api", g.Nodes[0]["title"])
+	assert.Equal(t, "west", g.Nodes[0]["detail__cluster"])
+}
+
+// Traffic from web in east to the api Service of west, through its mirror,
+// split between the api and api-canary workloads behind it.
+func prometheusSource() *multicluster.Source {
+	workload := func(namespace string, name string) []string {
+		return []string{"namespace", namespace, "workload_kind", "deployment", "workload_name", name}
+	}
+	destination := func(kind string, name string, cluster string) []string {
+		return []string{
+			"dst_namespace", "back", "dst_workload_kind", kind, "dst_workload_name", name, "dst_target_cluster", cluster,
+		}
+	}
+	member := func(name string) []string {
+		return []string{
+			"dst_namespace", "back", "dst_service", "api", "dst_workload_kind", "deployment", "dst_workload_name", name,
+		}
+	}
+	edge := func(value float64, source []string, destination []string) *model.SampleStream {
+		return series(value, append(append([]string{}, source...), destination...)...)
+	}
+
+	web := workload("front", "web")
+	gateway := workload("linkerd-multicluster", "linkerd-gateway")
+	cron := workload("back", "cron")
+
+	east := fakeAPI{
+		byWorkload: {series(10, web...)},
+		byEdge:     {edge(4, web, destination("unmeshed", "api-west", "west"))},
+		byDestination: {
+			series(4, destination("unmeshed", "api-west", "west")...),
+		},
+	}
+	west := fakeAPI{
+		byWorkload: {
+			series(6, workload("back", "api")...),
+			series(2, workload("back", "api-canary")...),
+			series(4, gateway...),
+			series(1, cron...),
+		},
+		byEdge: {
+			edge(3, gateway, destination("deployment", "api", "")),
+			edge(1, gateway, destination("deployment", "api-canary", "")),
+			edge(3, cron, destination("deployment", "api", "")),
+			edge(1, cron, destination("deployment", "api-canary", "")),
+		},
+		byMember: {series(6, member("api")...), series(2, member("api-canary")...)},
+	}
+
+	return multicluster.NewSource(
+		multicluster.Cluster{Name: "east", Source: prometheus.Client{API: east}},
+		multicluster.Cluster{Name: "west", Source: prometheus.Client{API: west}},
+	)
+}
+
+func TestSnapshotResolvesMirrors(t *testing.T) {
+	ctx := context.Background()
+
+	snapshot, err := prometheusSource().Snapshot(ctx, graph.Query{View: graph.WorkloadView})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rates := func(edges []graph.Edge, end func(graph.Edge) *graph.Node) map[string]float64 {
+		ids := map[string]float64{}
+		for _, edge := range edges {
+			ids[end(edge).ID()] = edge.RequestRate
+		}
+
+		return ids
+	}
+	source := func(edge graph.Edge) *graph.Node { return edge.Source }
+	destination := func(edge graph.Edge) *graph.Node { return edge.Destination }
+
+	web := snapshot.Node(ctx, graph.Resource{Name: "web", Namespace: "front", Kind: graph.DeploymentKind, Cluster: "east"})
+	edges := snapshot.UpstreamEdgesOf(ctx, web)
+
+	assert.Equal(t, map[string]float64{
+		"west__back__api__deployment":        3,
+		"west__back__api-canary__deployment": 1,
+	}, rates(edges, destination))
+
+	api := snapshot.Node(ctx, graph.Resource{Name: "api", Namespace: "back", Kind: graph.DeploymentKind, Cluster: "west"})
+	assert.Equal(t, 6.0, api.RequestVolume)
+
+	assert.Equal(t, map[string]float64{
+		"west__linkerd-multicluster__linkerd-gateway__deployment": 3,
+		"west__back__cron__deployment":                            3,
+		"east__front__web__deployment":                            3,
+	}, rates(snapshot.DownstreamEdgesOf(ctx, api), source))
+
+	// The mirrored service keeps the requests its clients sent to it.
+	edges = snapshot.DownstreamEdgesOf(ctx, &graph.Node{Resource: graph.Resource{
+		Name: "api", Namespace: "back", Kind: graph.ServiceKind, Cluster: "west",
+	}})
+	assert.Equal(t, map[string]float64{"east__front__web__deployment": 4}, rates(edges, source))
+}
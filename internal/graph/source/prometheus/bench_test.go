@@ -0,0 +1,106 @@
+package prometheus_test
+
+import (
+	"context"
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	benchWorkloads = 4000
+	benchFanOut    = 3
+)
+
+// meshQueries answers the builder queries for a synthetic mesh of workloads
+// calling each of the fanOut next ones.
+func meshQueries(workloads int, fanOut int) func(query string) model.Matrix {
+	resource := func(i int) (string, string) {
+		return fmt.Sprintf("ns%d", i%20), fmt.Sprintf("w%d", i)
+	}
+
+	nodes := model.Matrix{}
+	edges := model.Matrix{}
+
+	for i := 0; i < workloads; i++ {
+		namespace, name := resource(i)
+		nodes = append(nodes, withQuantile(matrix(1,
+			"namespace", namespace, "workload_kind", "deployment", "workload_name", name,
+		), "0.95")...)
+
+		for j := 1; j <= fanOut; j++ {
+			dstNamespace, dstName := resource((i + j) % workloads)
+			edges = append(edges, withQuantile(matrix(1,
+				"namespace", namespace, "workload_kind", "deployment", "workload_name", name,
+				"dst_namespace", dstNamespace, "dst_workload_kind", "deployment", "dst_workload_name", dstName,
+			), "0.95")...)
+		}
+	}
+
+	return func(query string) model.Matrix {
+		if strings.Contains(query, "outbound") {
+			return edges
+		}
+
+		return nodes
+	}
+}
+
+func benchBuilder(b *testing.B) *prometheus.Builder {
+	b.Helper()
+
+	client := prometheus.Client{API: fakeAPI{results: meshQueries(benchWorkloads, benchFanOut)}}
+
+	builder, err := client.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return builder
+}
+
+func BenchmarkBuilderBuild(b *testing.B) {
+	client := prometheus.Client{API: fakeAPI{results: meshQueries(benchWorkloads, benchFanOut)}}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := client.NewBuilder().Build(context.Background(), 0, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBuilderEdgesOf visits every resource of the mesh, as a graph of
+// the whole mesh does.
+func BenchmarkBuilderEdgesOf(b *testing.B) {
+	builder := benchBuilder(b)
+	ctx := context.Background()
+	resources := builder.Resources(ctx)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, resource := range resources {
+			if len(builder.EdgesOf(ctx, builder.Node(ctx, resource))) != 2*benchFanOut {
+				b.Fatalf("unexpected edges of %v", resource)
+			}
+		}
+	}
+}
+
+func BenchmarkBuilderNode(b *testing.B) {
+	builder := benchBuilder(b)
+	ctx := context.Background()
+	resource := graph.Resource{Namespace: "ns0", Name: fmt.Sprintf("w%d", benchWorkloads-20), Kind: graph.DeploymentKind}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		builder.Node(ctx, resource)
+	}
+}
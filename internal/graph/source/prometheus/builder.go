@@ -2,62 +2,121 @@ package prometheus
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/metrics"
+	"time"
 
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 )
 
 const (
-	// 1: additional filter labels
-	queryFormatSuccessRate = `
-	sum by (namespace, deployment, statefulset) (
-		irate(
-			response_total{classification="success", direction="inbound", namespace!="" %[1]s}[120s]
-		)
-	) /
-	sum by (namespace, deployment, statefulset) (
-		irate(
-			response_total{direction="inbound", namespace!="" %[1]s}[120s]
-		)
-	) >= 0`
-
-	queryFormatLatencyP95 = `
-	histogram_quantile(
-		0.95,
-		sum by (le, namespace, deployment, statefulset) (
-			rate(response_latency_ms_bucket{direction="inbound" %[1]s}[120s])
-		)
-	)
-	`
-
-	// 1: additional filter labels
-	queryFormatRequestVolume = `
-	sum by (namespace, deployment, statefulset) (
-		rate(request_total{direction="inbound" %[1]s}[120s])
-	) 
-	`
-
-	// 1: additional filter labels
-	queryFormatEdges = `
-	  sum by (deployment, statefulset, namespace, dst_namespace, dst_deployment, dst_statefulset)
-		  (rate(response_total{namespace!="", dst_namespace!="" %[1]s}[120s])
-		)
-	`
-	namespaceLabel      = model.LabelName("namespace")
-	dstNamespaceLabel   = model.LabelName("dst_namespace")
-	deploymentLabel     = model.LabelName("deployment")
-	statefulsetLabel    = model.LabelName("statefulset")
-	dstDeploymentLabel  = model.LabelName("dst_deployment")
-	dstStatefulsetLabel = model.LabelName("dst_statefulset")
+	namespaceLabel    = model.LabelName("namespace")
+	dstNamespaceLabel = model.LabelName("dst_namespace")
+	deploymentLabel   = model.LabelName("deployment")
+	statefulsetLabel  = model.LabelName("statefulset")
+	daemonsetLabel    = model.LabelName("daemonset")
+	cronjobLabel      = model.LabelName("cronjob")
+	// jobLabel holds the Kubernetes Job of the proxy series, job being the
+	// scrape job. The destination side keeps dst_job.
+	jobLabel        = model.LabelName("k8s_job")
+	dstJobLabel     = model.LabelName("dst_job")
+	replicasetLabel = model.LabelName("replicaset")
+	podLabel        = model.LabelName("pod")
+	dstServiceLabel = model.LabelName("dst_service")
+	authorityLabel  = model.LabelName("authority")
+
+	// quantileLabel is set by latencyFormat to the quantile of a latency.
+	quantileLabel = model.LabelName("quantile")
+
+	// workloadKindLabel and workloadNameLabel are set by relabelWorkload to
+	// the kind and name of the workload owning a series.
+	workloadKindLabel    = model.LabelName("workload_kind")
+	workloadNameLabel    = model.LabelName("workload_name")
+	dstWorkloadKindLabel = model.LabelName("dst_workload_kind")
+	dstWorkloadNameLabel = model.LabelName("dst_workload_name")
+
+	// targetClusterLabel is set, prefixed by dstPrefix, on the traffic sent
+	// to a service mirrored from another cluster.
+	targetClusterLabel    = model.LabelName("target_cluster")
+	dstTargetClusterLabel = model.LabelName("dst_target_cluster")
+
+	// dstPrefix turns a source label into its destination counterpart.
+	dstPrefix = "dst_"
+
+	// mirrorSuffix ends the name of mirrored services when not ended by the
+	// name of their cluster.
+	mirrorSuffix = "-remote"
 )
 
+// nodeLabels identify the resource of a sample in the node vectors.
+var nodeLabels = []model.LabelName{
+	namespaceLabel,
+	workloadKindLabel,
+	workloadNameLabel,
+}
+
+// destinationLabels identify the destination of a sample in the vectors
+// computed from the outbound side.
+var destinationLabels = []model.LabelName{
+	dstNamespaceLabel,
+	dstWorkloadKindLabel,
+	dstWorkloadNameLabel,
+	dstTargetClusterLabel,
+}
+
+// edgeLabels identify the source and destination of a sample in the edge vectors.
+var edgeLabels = []model.LabelName{
+	namespaceLabel,
+	workloadKindLabel,
+	workloadNameLabel,
+	dstNamespaceLabel,
+	dstWorkloadKindLabel,
+	dstWorkloadNameLabel,
+	dstTargetClusterLabel,
+}
+
 type Builder struct {
 	client              *Client
+	window              rangeWindow
+	rateWindow          time.Duration
+	step                time.Duration
+	quantiles           []float64
 	vectorSuccessRate   model.Vector
-	vectorLatencyP95    model.Vector
+	vectorLatency       model.Vector
 	vectorRequestVolume model.Vector
 	vectorEdges         model.Vector
+	vectorEdgeSuccess   model.Vector
+	vectorEdgeLatency   model.Vector
+
+	// Stats of synthetic destinations, from the outbound side.
+	vectorDstSuccessRate   model.Vector
+	vectorDstLatency       model.Vector
+	vectorDstRequestVolume model.Vector
+
+	// Requests received by workloads through each Service.
+	vectorMembers model.Vector
+
+	// Indices of the vectors, set once built.
+	indexSuccessRate      vectorIndex
+	indexLatency          vectorIndex
+	indexRequestVolume    vectorIndex
+	indexEdges            edgeIndex
+	indexEdgeSuccess      vectorIndex
+	indexEdgeLatency      vectorIndex
+	indexDstSuccessRate   vectorIndex
+	indexDstLatency       vectorIndex
+	indexDstRequestVolume vectorIndex
+
+	// warnings tell why some stats may be missing.
+	warnings []string
+
+	// members maps a Service to the workloads behind it and services a
+	// workload to the Services in front of it.
+	members  map[graph.Resource][]graph.Member
+	services map[graph.Resource][]graph.Resource
 }
 
 func (prometheus Client) NewBuilder() *Builder {
@@ -65,163 +124,342 @@ func (prometheus Client) NewBuilder() *Builder {
 		client:              &prometheus,
 		vectorSuccessRate:   nil,
 		vectorRequestVolume: nil,
-		vectorLatencyP95:    nil,
+		vectorLatency:       nil,
 		vectorEdges:         nil,
+		vectorEdgeSuccess:   nil,
+		vectorEdgeLatency:   nil,
+
+		vectorDstSuccessRate:   nil,
+		vectorDstLatency:       nil,
+		vectorDstRequestVolume: nil,
+
+		vectorMembers: nil,
+		members:       map[graph.Resource][]graph.Member{},
+		services:      map[graph.Resource][]graph.Resource{},
 	}
 }
 
+// WithQuantiles sets the latency quantiles computed by Build, the default
+// quantile when empty.
+func (builder *Builder) WithQuantiles(quantiles []float64) *Builder {
+	builder.quantiles = quantiles
+
+	return builder
+}
+
+// WithRateWindow sets the window of the rates computed by Build, derived from
+// the time range and the scrape interval when zero.
+func (builder *Builder) WithRateWindow(window time.Duration) *Builder {
+	builder.rateWindow = window
+
+	return builder
+}
+
+// WithStep sets the resolution of the range queries of Build, derived from
+// the time range when zero.
+func (builder *Builder) WithStep(step time.Duration) *Builder {
+	builder.step = step
+
+	return builder
+}
+
 func (builder *Builder) Build(ctx context.Context, from int64, to int64) (*Builder, error) {
-	chVectorEdges := make(chan buildVectorResult, 1)
-	chVectorSuccessRate := make(chan buildVectorResult, 1)
-	chVectorRequestVolume := make(chan buildVectorResult, 1)
-	chVectorLatencyP95 := make(chan buildVectorResult, 1)
-
-	go buildVector(ctx,
-		from,
-		to,
-		builder.client,
-		chVectorSuccessRate,
-		fmt.Sprintf(queryFormatSuccessRate, builder.client.Labels))
-
-	go buildVector(ctx,
-		from,
-		to,
-		builder.client,
-		chVectorEdges,
-		fmt.Sprintf(
-			queryFormatEdges,
-			builder.client.Labels))
-
-	go buildVector(ctx,
-		from,
-		to,
-		builder.client,
-		chVectorRequestVolume,
-		fmt.Sprintf(
-			queryFormatRequestVolume,
-			builder.client.Labels))
-
-	go buildVector(ctx,
-		from,
-		to,
-		builder.client,
-		chVectorLatencyP95,
-		fmt.Sprintf(
-			queryFormatLatencyP95,
-			builder.client.Labels))
-
-	vectorEdges := <-chVectorEdges
-	vectorSuccessRate := <-chVectorSuccessRate
-	vectorRequestVolume := <-chVectorRequestVolume
-	vectorLatencyP95 := <-chVectorLatencyP95
-
-	if vectorEdges.err != nil {
-		return nil, fmt.Errorf("failed to build vector edges: %w", vectorEdges.err)
+	builder.window = builder.client.window(from, to, builder.rateWindow, builder.step)
+
+	r := builder.client.renderer(builder.window, builder.quantiles)
+	templates := builder.client.templates()
+
+	queries := []vectorQuery{
+		{
+			name:     "edges",
+			query:    r.render(templates.edges, outbound, edgeLabels, relabelEdge),
+			target:   &builder.vectorEdges,
+			required: true,
+		},
+		{
+			name:   "success rate",
+			query:  r.render(templates.successRate, inbound, nodeLabels, relabelSource),
+			target: &builder.vectorSuccessRate,
+		},
+		{
+			name:   "latency",
+			query:  r.renderLatency(inbound, nodeLabels, relabelSource),
+			target: &builder.vectorLatency,
+		},
+		{
+			name:   "volume",
+			query:  r.render(templates.volume, inbound, nodeLabels, relabelSource),
+			target: &builder.vectorRequestVolume,
+		},
+		{
+			name:   "edge success rate",
+			query:  r.render(templates.successRate, outbound, edgeLabels, relabelEdge),
+			target: &builder.vectorEdgeSuccess,
+		},
+		{
+			name:   "edge latency",
+			query:  r.renderLatency(outbound, edgeLabels, relabelEdge),
+			target: &builder.vectorEdgeLatency,
+		},
+		{
+			name:   "destination success rate",
+			query:  r.render(templates.successRate, outbound, destinationLabels, relabelDestination),
+			target: &builder.vectorDstSuccessRate,
+		},
+		{
+			name:   "destination latency",
+			query:  r.renderLatency(outbound, destinationLabels, relabelDestination),
+			target: &builder.vectorDstLatency,
+		},
+		{
+			name:   "destination volume",
+			query:  r.render(templates.volume, outbound, destinationLabels, relabelDestination),
+			target: &builder.vectorDstRequestVolume,
+		},
+		{
+			name:   "service members",
+			query:  r.render(templates.volume, outbound, memberLabels, relabelMember),
+			target: &builder.vectorMembers,
+		},
 	}
-
-	if vectorSuccessRate.err != nil {
-		return nil, fmt.Errorf("failed to build vector success rate: %w", vectorSuccessRate.err)
+	if r.err != nil {
+		return nil, r.err
 	}
 
-	if vectorLatencyP95.err != nil {
-		return nil, fmt.Errorf("failed to build vector latency : %w", vectorLatencyP95.err)
+	warnings, err := buildVectors(ctx, from, to, builder.window.step, builder.client, queries)
+	if err != nil {
+		return nil, err
 	}
 
-	if vectorRequestVolume.err != nil {
-		return nil, fmt.Errorf("failed to build vector volume: %w", vectorRequestVolume.err)
+	builder.warnings = warnings
+
+	// Without inbound volume, meshed destinations cannot be told apart.
+	if builder.vectorRequestVolume != nil {
+		markUnmeshed(builder.vectorRequestVolume,
+			builder.vectorEdges, builder.vectorEdgeSuccess, builder.vectorEdgeLatency,
+			builder.vectorDstSuccessRate, builder.vectorDstLatency, builder.vectorDstRequestVolume,
+			builder.vectorMembers,
+		)
 	}
 
-	builder.vectorEdges = vectorEdges.vector
-	builder.vectorSuccessRate = vectorSuccessRate.vector
-	builder.vectorLatencyP95 = vectorLatencyP95.vector
-	builder.vectorRequestVolume = vectorRequestVolume.vector
+	stitchMirrors(
+		builder.vectorEdges, builder.vectorEdgeSuccess, builder.vectorEdgeLatency,
+		builder.vectorDstSuccessRate, builder.vectorDstLatency, builder.vectorDstRequestVolume,
+	)
+
+	builder.indexSuccessRate = newVectorIndex(builder.vectorSuccessRate, nodeLabels)
+	builder.indexLatency = newVectorIndex(builder.vectorLatency, nodeLabels)
+	builder.indexRequestVolume = newVectorIndex(builder.vectorRequestVolume, nodeLabels)
+	builder.indexEdges = newEdgeIndex(builder.vectorEdges)
+	builder.indexEdgeSuccess = newVectorIndex(builder.vectorEdgeSuccess, edgeLabels)
+	builder.indexEdgeLatency = newVectorIndex(builder.vectorEdgeLatency, edgeLabels)
+	builder.indexDstSuccessRate = newVectorIndex(builder.vectorDstSuccessRate, destinationLabels)
+	builder.indexDstLatency = newVectorIndex(builder.vectorDstLatency, destinationLabels)
+	builder.indexDstRequestVolume = newVectorIndex(builder.vectorDstRequestVolume, destinationLabels)
+
+	builder.indexMembers()
 
 	return builder, nil
 }
 
-type buildVectorResult struct {
-	vector model.Vector
-	err    error
+// indexMembers fills the Services in front of each workload and the workloads
+// behind each Service, with their share of its requests.
+func (builder *Builder) indexMembers() {
+	totals := map[graph.Resource]float64{}
+
+	for _, sample := range builder.vectorMembers {
+		service, workload, ok := memberSample(sample.Metric)
+		if !ok {
+			continue
+		}
+
+		totals[service] += float64(sample.Value)
+		builder.members[service] = append(builder.members[service], graph.Member{
+			Resource: workload,
+			Share:    float64(sample.Value),
+		})
+		builder.services[workload] = append(builder.services[workload], service)
+	}
+
+	for service, members := range builder.members {
+		for i := range members {
+			if totals[service] > 0 {
+				members[i].Share /= totals[service]
+			} else {
+				members[i].Share = 1 / float64(len(members))
+			}
+		}
+	}
 }
 
-func buildVector(ctx context.Context, from int64, to int64, client *Client, ch chan buildVectorResult, q string) {
-	vector, err := client.queryRange(ctx, q, from, to)
-	ch <- buildVectorResult{vector, err}
+// Size estimates the memory used by the builder, in bytes.
+func (builder *Builder) Size() int {
+	return vectorsSize(
+		builder.vectorSuccessRate, builder.vectorLatency, builder.vectorRequestVolume,
+		builder.vectorEdges, builder.vectorEdgeSuccess, builder.vectorEdgeLatency,
+		builder.vectorDstSuccessRate, builder.vectorDstLatency, builder.vectorDstRequestVolume,
+		builder.vectorMembers,
+	)
 }
 
-// Node returns the graph.Node associated with resource.
-func (builder Builder) Node(ctx context.Context, resource graph.Resource) *graph.Node {
-	kind := resourceKindToLabel(resource.Kind)
-	namespace := model.LabelValue(resource.Namespace)
-	name := model.LabelValue(resource.Name)
+// Warnings tells about the queries that failed, whose stats are missing, and
+// about the warnings of Prometheus.
+func (builder *Builder) Warnings() []string {
+	return builder.warnings
+}
 
-	return &graph.Node{
-		Resource:      resource,
-		SuccessRate:   float64(findInVector(builder.vectorSuccessRate, kind, namespace, name)),
-		RequestVolume: float64(findInVector(builder.vectorRequestVolume, kind, namespace, name)),
-		LatencyP95:    float64(findInVector(builder.vectorLatencyP95, kind, namespace, name)),
-	}
+// relabelEdge is relabelSource followed by relabelDestination.
+func relabelEdge(expr string) string {
+	return relabelDestination(relabelSource(expr))
 }
 
-func findInVector(vector model.Vector, kind model.LabelName, namespace model.LabelValue, name model.LabelValue) model.SampleValue {
-	if vector == nil {
-		return 0
+// vectorQuery is a query whose result is stored in target once built. The
+// failure of a required query fails the build, while the others only leave
+// their target empty.
+type vectorQuery struct {
+	name     string
+	query    string
+	target   *model.Vector
+	required bool
+}
+
+type buildVectorResult struct {
+	vector   model.Vector
+	warnings prom.Warnings
+	err      error
+}
+
+// buildVectors runs every query concurrently, range queries being resolved by
+// step. Targets are only set when all required queries succeed, and the
+// warnings returned tell about the failed queries and the warnings of
+// Prometheus.
+func buildVectors(
+	ctx context.Context, from int64, to int64, step time.Duration, client *Client, queries []vectorQuery,
+) ([]string, error) {
+	channels := make([]chan buildVectorResult, len(queries))
+
+	for i, query := range queries {
+		channels[i] = make(chan buildVectorResult, 1)
+
+		go buildVector(ctx, from, to, step, client, channels[i], query)
 	}
 
-	for _, sample := range vector {
-		if sample.Metric[namespaceLabel] == namespace && sample.Metric[kind] == name {
-			return sample.Value
+	results := make([]buildVectorResult, len(queries))
+
+	for i := range queries {
+		results[i] = <-channels[i]
+	}
+
+	for i, query := range queries {
+		if results[i].err != nil && query.required {
+			return nil, fmt.Errorf("failed to build vector %s: %w", query.name, results[i].err)
 		}
 	}
 
-	return 0
-}
+	warnings := []string{}
 
-func (builder Builder) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
-	edges := []graph.Edge{}
+	for i, query := range queries {
+		for _, warning := range results[i].warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", query.name, warning))
+		}
 
-	if builder.vectorEdges == nil {
-		return edges
-	}
+		if results[i].err != nil {
+			// Leave the query, too long to be read, out of the warning.
+			cause := errors.Unwrap(results[i].err)
+			if cause == nil {
+				cause = results[i].err
+			}
+
+			warnings = append(warnings, fmt.Sprintf("%s unavailable: %s", query.name, cause))
 
-	for _, sample := range builder.vectorEdges {
-		if !validEdgeSample(*sample) {
 			continue
 		}
 
-		if string(sample.Metric[namespaceLabel]) != node.Resource.Namespace {
-			continue
+		*query.target = results[i].vector
+	}
+
+	return warnings, nil
+}
+
+func buildVector(
+	ctx context.Context, from int64, to int64, step time.Duration, client *Client, ch chan buildVectorResult, q vectorQuery,
+) {
+	start := time.Now()
+	vector, warnings, err := client.queryVector(ctx, q.query, from, to, step)
+
+	metrics.ObserveQuery(q.name, start, err)
+	ch <- buildVectorResult{vector, warnings, err}
+}
+
+// Node returns the graph.Node associated with resource. Synthetic resources
+// and resources of other clusters take their stats from the outbound side of
+// their clients.
+func (builder Builder) Node(ctx context.Context, resource graph.Resource) *graph.Node {
+	if resource.Kind.Synthetic() || resource.Cluster != "" {
+		metric := model.Metric{
+			dstNamespaceLabel:     model.LabelValue(resource.Namespace),
+			dstWorkloadKindLabel:  kindValue(resource.Kind),
+			dstWorkloadNameLabel:  model.LabelValue(resource.Name),
+			dstTargetClusterLabel: model.LabelValue(resource.Cluster),
 		}
 
-		if v, ok := sample.Metric[deploymentLabel]; ok {
-			if string(v) != node.Resource.Name {
-				continue
-			}
-		} else if v, ok := sample.Metric[statefulsetLabel]; ok {
-			if string(v) != node.Resource.Name {
-				continue
-			}
-		} else {
-			continue
+		volume := float64(builder.indexDstRequestVolume.value(metric))
+		successRate, known := builder.indexDstSuccessRate.successRate(metric, volume)
+
+		return &graph.Node{
+			Resource:         resource,
+			SuccessRate:      successRate,
+			SuccessRateKnown: known,
+			RequestVolume:    volume,
+			Latencies:        builder.indexDstLatency.latencies(metric),
 		}
+	}
+
+	metric := model.Metric{
+		namespaceLabel:    model.LabelValue(resource.Namespace),
+		workloadKindLabel: kindValue(resource.Kind),
+		workloadNameLabel: model.LabelValue(resource.Name),
+	}
+
+	volume := float64(builder.indexRequestVolume.value(metric))
+	successRate, known := builder.indexSuccessRate.successRate(metric, volume)
 
-		var resource graph.Resource
+	return &graph.Node{
+		Resource:         resource,
+		SuccessRate:      successRate,
+		SuccessRateKnown: known,
+		RequestVolume:    volume,
+		Latencies:        builder.indexLatency.latencies(metric),
+	}
+}
 
-		resource.Namespace = string(sample.Metric[dstNamespaceLabel])
+// edge returns the graph.Edge between source and destination, with its stats
+// taken from the edge vectors entry matching sample.
+func (builder Builder) edge(source *graph.Node, destination *graph.Node, sample *model.Sample) graph.Edge {
+	successRate, known := builder.indexEdgeSuccess.successRate(sample.Metric, float64(sample.Value))
+
+	return graph.Edge{
+		Source:           source,
+		Destination:      destination,
+		RequestRate:      float64(sample.Value),
+		SuccessRate:      successRate,
+		SuccessRateKnown: known,
+		Latencies:        builder.indexEdgeLatency.latencies(sample.Metric),
+	}
+}
 
-		if v, ok := sample.Metric[dstDeploymentLabel]; ok {
-			resource.Kind = graph.DeploymentKind
-			resource.Name = string(v)
-		} else if v, ok := sample.Metric[dstStatefulsetLabel]; ok {
-			resource.Kind = graph.StatefulsetKind
-			resource.Name = string(v)
-		} else {
+func (builder Builder) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, sample := range builder.indexEdges.from(node.Resource) {
+		resource := sampleResource(sample.Metric, dstPrefix)
+		if resource.Kind == graph.UndefinedKind {
 			continue
 		}
 
 		edgeNode := builder.Node(ctx, resource)
 
-		edges = append(edges, graph.Edge{Source: node, Destination: edgeNode})
+		edges = append(edges, builder.edge(node, edgeNode, sample))
 	}
 
 	return edges
@@ -230,51 +468,59 @@ func (builder Builder) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []
 func (builder Builder) DownstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
 	edges := []graph.Edge{}
 
-	if builder.vectorEdges == nil {
-		return edges
-	}
-
-	for _, sample := range builder.vectorEdges {
-		if !validEdgeSample(*sample) {
+	for _, sample := range builder.indexEdges.to(node.Resource) {
+		resource := sampleResource(sample.Metric, "")
+		if resource.Kind == graph.UndefinedKind {
 			continue
 		}
 
-		if string(sample.Metric[dstNamespaceLabel]) != node.Resource.Namespace {
-			continue
-		}
+		edgeNode := builder.Node(ctx, resource)
 
-		if v, ok := sample.Metric[dstDeploymentLabel]; ok {
-			if string(v) != node.Resource.Name {
-				continue
-			}
-		} else if v, ok := sample.Metric[dstStatefulsetLabel]; ok {
-			if string(v) != node.Resource.Name {
-				continue
-			}
-		} else {
-			continue
-		}
+		edges = append(edges, builder.edge(edgeNode, node, sample))
+	}
+
+	return edges
+}
 
-		var resource graph.Resource
+// Resources returns every resource seen in the edges and request volume
+// vectors.
+func (builder Builder) Resources(ctx context.Context) []graph.Resource {
+	resources := []graph.Resource{}
+	seen := map[graph.Resource]bool{}
 
-		resource.Namespace = string(sample.Metric[namespaceLabel])
+	add := func(resource graph.Resource) {
+		if resource.Kind == graph.UndefinedKind || seen[resource] {
+			return
+		}
 
-		if v, ok := sample.Metric[deploymentLabel]; ok {
-			resource.Kind = graph.DeploymentKind
-			resource.Name = string(v)
-		} else if v, ok := sample.Metric[statefulsetLabel]; ok {
-			resource.Kind = graph.StatefulsetKind
-			resource.Name = string(v)
-		} else {
+		seen[resource] = true
+		resources = append(resources, resource)
+	}
+
+	for _, sample := range builder.vectorEdges {
+		if !validEdgeSample(*sample) {
 			continue
 		}
 
-		edgeNode := builder.Node(ctx, resource)
+		add(sampleResource(sample.Metric, ""))
+		add(sampleResource(sample.Metric, dstPrefix))
+	}
 
-		edges = append(edges, graph.Edge{Source: edgeNode, Destination: node})
+	for _, sample := range builder.vectorRequestVolume {
+		add(sampleResource(sample.Metric, ""))
 	}
 
-	return edges
+	return resources
+}
+
+// Members returns the workloads of this cluster behind service.
+func (builder Builder) Members(ctx context.Context, service graph.Resource) []graph.Member {
+	return builder.members[service]
+}
+
+// Services returns the Services in front of workload.
+func (builder Builder) Services(ctx context.Context, workload graph.Resource) []graph.Resource {
+	return builder.services[workload]
 }
 
 func (builder Builder) EdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
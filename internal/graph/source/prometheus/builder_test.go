@@ -0,0 +1,764 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAPI struct {
+	results func(query string) model.Matrix
+}
+
+func (f fakeAPI) QueryRange(
+	ctx context.Context, query string, r prom.Range, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	return f.results(query), nil, nil
+}
+
+// Query answers with the last value of every series of the results.
+func (f fakeAPI) Query(
+	ctx context.Context, query string, ts time.Time, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	vector := model.Vector{}
+
+	for _, stream := range f.results(query) {
+		vector = append(vector, &model.Sample{
+			Metric:    stream.Metric,
+			Value:     stream.Values[len(stream.Values)-1].Value,
+			Timestamp: model.TimeFromUnixNano(ts.UnixNano()),
+		})
+	}
+
+	return vector, nil, nil
+}
+
+// rawSeries is a series as scraped from the proxies, answered to the queries
+// containing selector.
+type rawSeries struct {
+	selector string
+	labels   model.Metric
+	value    float64
+}
+
+var (
+	labelReplace = regexp.MustCompile(`,\s*"([^"]*)",\s*"([^"]*)",\s*"([^"]*)",\s*"([^"]*)"\s*\)`)
+	sumBy        = regexp.MustCompile(`sum by \(([^)]*)\)`)
+)
+
+// relabellingAPI answers the volume and edge queries by evaluating their
+// label_replace calls and outer sum on series, like Prometheus would. The
+// other queries get empty results.
+func relabellingAPI(series ...rawSeries) fakeAPI {
+	return fakeAPI{results: func(query string) model.Matrix {
+		if strings.Contains(query, "classification") || strings.Contains(query, "histogram_quantile") {
+			return model.Matrix{}
+		}
+
+		grouping := strings.Split(sumBy.FindStringSubmatch(query)[1], ", ")
+		sums := map[model.Fingerprint]*model.SampleStream{}
+		result := model.Matrix{}
+
+		for _, raw := range series {
+			if !strings.Contains(query, raw.selector) {
+				continue
+			}
+
+			metric := raw.labels.Clone()
+
+			// Calls are listed from the innermost, the first applied.
+			for _, args := range labelReplace.FindAllStringSubmatch(query, -1) {
+				source := regexp.MustCompile("^(?:" + args[4] + ")$")
+				value := string(metric[model.LabelName(args[3])])
+
+				if match := source.FindStringSubmatchIndex(value); match != nil {
+					metric[model.LabelName(args[1])] = model.LabelValue(
+						source.ExpandString(nil, args[2], value, match))
+				}
+			}
+
+			grouped := model.Metric{}
+			for _, label := range grouping {
+				if value, ok := metric[model.LabelName(label)]; ok && value != "" {
+					grouped[model.LabelName(label)] = value
+				}
+			}
+
+			stream, ok := sums[grouped.Fingerprint()]
+			if !ok {
+				stream = &model.SampleStream{Metric: grouped, Values: []model.SamplePair{{}}}
+				sums[grouped.Fingerprint()] = stream
+				result = append(result, stream)
+			}
+
+			stream.Values[0].Value += model.SampleValue(raw.value)
+		}
+
+		return result
+	}}
+}
+
+func matrix(value float64, labels ...string) model.Matrix {
+	metric := model.Metric{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		metric[model.LabelName(labels[i])] = model.LabelValue(labels[i+1])
+	}
+
+	return model.Matrix{
+		&model.SampleStream{
+			Metric: metric,
+			Values: []model.SamplePair{{Timestamp: 0, Value: model.SampleValue(value)}},
+		},
+	}
+}
+
+// withQuantile labels every series of m with quantile, as latency queries do.
+func withQuantile(m model.Matrix, quantile string) model.Matrix {
+	for _, stream := range m {
+		stream.Metric[model.LabelName("quantile")] = model.LabelValue(quantile)
+	}
+
+	return m
+}
+
+// isInboundVolume reports whether query is the inbound request volume query,
+// telling meshed workloads.
+func isInboundVolume(query string) bool {
+	return strings.Contains(query, `direction="inbound"`) &&
+		!strings.Contains(query, "response_latency_ms_bucket") &&
+		!strings.Contains(query, `classification="success"`)
+}
+
+func edgeQueries(query string) model.Matrix {
+	edge := []string{
+		"namespace", "foo",
+		"workload_kind", "deployment",
+		"workload_name", "web",
+		"dst_namespace", "bar",
+		"dst_workload_kind", "deployment",
+		"dst_workload_name", "api",
+	}
+	daemonsetEdge := []string{
+		"namespace", "logging",
+		"workload_kind", "daemonset",
+		"workload_name", "shipper",
+		"dst_namespace", "bar",
+		"dst_workload_kind", "deployment",
+		"dst_workload_name", "api",
+	}
+
+	externalEdge := []string{
+		"namespace", "foo",
+		"workload_kind", "deployment",
+		"workload_name", "web",
+		"dst_workload_kind", "external",
+		"dst_workload_name", "api.example.com:443",
+	}
+
+	switch {
+	case isInboundVolume(query):
+		return matrix(11, "namespace", "bar", "workload_kind", "deployment", "workload_name", "api")
+	case strings.Contains(query, "response_latency_ms_bucket") && strings.Contains(query, "outbound"):
+		return withQuantile(append(matrix(42, edge...), matrix(250, externalEdge...)...), "0.95")
+	case strings.Contains(query, "classification=\"success\"") && strings.Contains(query, "outbound"):
+		return append(matrix(0.5, edge...), matrix(0.9, externalEdge...)...)
+	case strings.Contains(query, "response_total") && strings.Contains(query, "outbound"):
+		return append(matrix(10, edge...), append(matrix(1, daemonsetEdge...), matrix(3, externalEdge...)...)...)
+	default:
+		return model.Matrix{}
+	}
+}
+
+func Test_BuilderEdgeStats(t *testing.T) {
+	client := prometheus.Client{API: fakeAPI{results: edgeQueries}}
+
+	b, err := client.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := b.Node(context.Background(), graph.Resource{Name: "web", Namespace: "foo", Kind: graph.DeploymentKind})
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 2) {
+		assert.Equal(t, "bar__api__deployment", edges[0].Destination.ID())
+		assert.Equal(t, 10.0, edges[0].RequestRate)
+		assert.Equal(t, 0.5, edges[0].SuccessRate)
+		assert.Equal(t, 42.0, edges[0].Latencies[0.95])
+
+		external := edges[1].Destination
+		assert.Equal(t, graph.ExternalKind, external.Resource.Kind)
+		assert.Equal(t, "api.example.com:443", external.Resource.Name)
+		assert.Equal(t, 3.0, external.RequestVolume)
+		assert.Equal(t, 0.9, external.SuccessRate)
+		assert.Equal(t, 250.0, external.Latencies[0.95])
+	}
+
+	api := b.Node(context.Background(), graph.Resource{Name: "api", Namespace: "bar", Kind: graph.DeploymentKind})
+
+	edges = b.DownstreamEdgesOf(context.Background(), api)
+	if assert.Len(t, edges, 2) {
+		assert.Equal(t, "foo__web__deployment", edges[0].Source.ID())
+		assert.Equal(t, 10.0, edges[0].RequestRate)
+		assert.Equal(t, "logging__shipper__daemonset", edges[1].Source.ID())
+		assert.Equal(t, 1.0, edges[1].RequestRate)
+	}
+}
+
+func Test_BuilderUnmeshedWorkloads(t *testing.T) {
+	edge := func(kind string, name string) []string {
+		return []string{
+			"namespace", "foo",
+			"workload_kind", "deployment",
+			"workload_name", "web",
+			"dst_namespace", "foo",
+			"dst_workload_kind", kind,
+			"dst_workload_name", name,
+		}
+	}
+	meshed := edge("deployment", "api")
+	legacy := edge("deployment", "legacy")
+	pod := edge("pod", "batch-7f9c")
+
+	client := prometheus.Client{API: fakeAPI{results: func(query string) model.Matrix {
+		destination := strings.Contains(query, "sum by (dst_namespace")
+
+		switch {
+		case isInboundVolume(query):
+			return matrix(5, "namespace", "foo", "workload_kind", "deployment", "workload_name", "api")
+		case destination && strings.Contains(query, `classification="success"`):
+			return append(matrix(0.5, legacy[6:]...), matrix(0.25, pod[6:]...)...)
+		case destination && strings.Contains(query, "response_total"):
+			return append(matrix(2, legacy[6:]...), matrix(1, pod[6:]...)...)
+		case strings.Contains(query, "response_total") && !strings.Contains(query, `classification="success"`):
+			return append(matrix(5, meshed...), append(matrix(2, legacy...), matrix(1, pod...)...)...)
+		default:
+			return model.Matrix{}
+		}
+	}}}
+
+	b, err := client.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := b.Node(context.Background(), graph.Resource{Name: "web", Namespace: "foo", Kind: graph.DeploymentKind})
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 3) {
+		assert.Equal(t, "foo__api__deployment", edges[0].Destination.ID())
+
+		assert.Equal(t, "foo__legacy__unmeshed", edges[1].Destination.ID())
+		assert.Equal(t, 2.0, edges[1].Destination.RequestVolume)
+		assert.Equal(t, 0.5, edges[1].Destination.SuccessRate)
+
+		assert.Equal(t, "foo__batch-7f9c__unmeshed", edges[2].Destination.ID())
+		assert.Equal(t, 1.0, edges[2].Destination.RequestVolume)
+		assert.Equal(t, 0.25, edges[2].Destination.SuccessRate)
+	}
+}
+
+func Test_BuilderWorkloadKinds(t *testing.T) {
+	proxy := func(labels ...string) model.Metric {
+		metric := model.Metric{"namespace": "foo", "job": "linkerd-proxy"}
+		for i := 0; i+1 < len(labels); i += 2 {
+			metric[model.LabelName(labels[i])] = model.LabelValue(labels[i+1])
+		}
+
+		return metric
+	}
+	inbound := `request_total{direction="inbound"`
+	outbound := `response_total{direction="outbound"`
+
+	client := prometheus.Client{API: relabellingAPI(
+		rawSeries{selector: inbound, labels: proxy("pod", "web-1-x7k2p", "replicaset", "web-1"), value: 4},
+		rawSeries{selector: inbound, labels: proxy("pod", "batch-q8w4z", "k8s_job", "batch"), value: 1},
+		rawSeries{selector: inbound, labels: proxy("pod", "solo"), value: 2},
+		rawSeries{selector: outbound, labels: proxy(
+			"pod", "web-1-x7k2p", "replicaset", "web-1",
+			"dst_namespace", "foo", "dst_pod", "solo",
+		), value: 2},
+		rawSeries{selector: outbound, labels: proxy(
+			"pod", "web-1-x7k2p", "replicaset", "web-1",
+			"dst_namespace", "foo", "dst_pod", "batch-q8w4z", "dst_job", "batch",
+		), value: 1},
+	)}
+
+	b, err := client.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ElementsMatch(t, []graph.Resource{
+		{Namespace: "foo", Name: "web-1", Kind: graph.ReplicasetKind},
+		{Namespace: "foo", Name: "batch", Kind: graph.JobKind},
+		{Namespace: "foo", Name: "solo", Kind: graph.PodKind},
+	}, b.Resources(context.Background()))
+
+	web := b.Node(context.Background(), graph.Resource{Namespace: "foo", Name: "web-1", Kind: graph.ReplicasetKind})
+	assert.Equal(t, 4.0, web.RequestVolume)
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	destinations := make([]string, 0, len(edges))
+
+	for _, edge := range edges {
+		destinations = append(destinations, edge.Destination.ID())
+	}
+
+	assert.ElementsMatch(t, []string{"foo__solo__pod", "foo__batch__job"}, destinations)
+}
+
+func Test_BuilderMeshedPods(t *testing.T) {
+	inbound := `request_total{direction="inbound"`
+	outbound := `response_total{direction="outbound"`
+	client := func(dstPod string) model.Metric {
+		return model.Metric{
+			"namespace": "foo", "job": "linkerd-proxy", "pod": "web-1-x7k2p", "replicaset": "web-1",
+			"dst_namespace": "foo", "dst_pod": model.LabelValue(dstPod),
+		}
+	}
+
+	b, err := prometheus.Client{API: relabellingAPI(
+		rawSeries{selector: inbound, labels: model.Metric{"namespace": "foo", "job": "linkerd-proxy", "pod": "solo"}, value: 2},
+		rawSeries{selector: outbound, labels: client("solo"), value: 2},
+		rawSeries{selector: outbound, labels: client("bare"), value: 1},
+	)}.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := b.Node(context.Background(), graph.Resource{Namespace: "foo", Name: "web-1", Kind: graph.ReplicasetKind})
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 2) {
+		destinations := map[string]float64{}
+		for _, edge := range edges {
+			destinations[edge.Destination.ID()] = edge.Destination.RequestVolume
+		}
+
+		// solo has a proxy, bare does not.
+		assert.Equal(t, map[string]float64{"foo__solo__pod": 2, "foo__bare__unmeshed": 1}, destinations)
+	}
+}
+
+func Test_BuilderSuccessRateKnown(t *testing.T) {
+	web := []string{"namespace", "foo", "workload_kind", "deployment", "workload_name", "web"}
+	api := []string{"namespace", "foo", "workload_kind", "deployment", "workload_name", "api"}
+
+	client := prometheus.Client{API: fakeAPI{results: func(query string) model.Matrix {
+		switch {
+		case isInboundVolume(query):
+			return append(matrix(2, web...), matrix(3, api...)...)
+		case strings.Contains(query, `direction="inbound"`) && strings.Contains(query, `classification="success"`):
+			return matrix(0.5, web...)
+		default:
+			return model.Matrix{}
+		}
+	}}}
+
+	b, err := client.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := b.Node(context.Background(), graph.Resource{Name: "web", Namespace: "foo", Kind: graph.DeploymentKind})
+	assert.True(t, node.SuccessRateKnown)
+	assert.Equal(t, 0.5, node.SuccessRate)
+
+	// Every request to api failed.
+	node = b.Node(context.Background(), graph.Resource{Name: "api", Namespace: "foo", Kind: graph.DeploymentKind})
+	assert.True(t, node.SuccessRateKnown)
+	assert.Equal(t, 0.0, node.SuccessRate)
+
+	node = b.Node(context.Background(), graph.Resource{Name: "db", Namespace: "foo", Kind: graph.DeploymentKind})
+	assert.False(t, node.SuccessRateKnown)
+}
+
+func Test_BuilderQueriesGroupByWorkload(t *testing.T) {
+	var mu sync.Mutex
+
+	queries := []string{}
+	client := prometheus.Client{API: fakeAPI{results: func(query string) model.Matrix {
+		mu.Lock()
+		defer mu.Unlock()
+
+		queries = append(queries, query)
+
+		return model.Matrix{}
+	}}}
+
+	_, err := client.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range queries {
+		for _, kind := range graph.ResourceKinds {
+			assert.Contains(t, query, `workload_kind", "`+kind.String()+`", "`)
+		}
+	}
+}
+
+func Test_BuilderStitchesMirrors(t *testing.T) {
+	gateway := []string{
+		"namespace", "foo",
+		"workload_kind", "deployment",
+		"workload_name", "web",
+		"dst_namespace", "bar",
+		"dst_workload_kind", "unmeshed",
+		"dst_workload_name", "api-west",
+		"dst_target_cluster", "west",
+	}
+	remote := []string{
+		"namespace", "foo",
+		"workload_kind", "deployment",
+		"workload_name", "web",
+		"dst_namespace", "bar",
+		"dst_workload_kind", "unmeshed",
+		"dst_workload_name", "db-remote",
+		"dst_target_cluster", "east",
+	}
+	flat := []string{
+		"namespace", "foo",
+		"workload_kind", "deployment",
+		"workload_name", "web",
+		"dst_namespace", "bar",
+		"dst_workload_kind", "deployment",
+		"dst_workload_name", "cache",
+		"dst_target_cluster", "west",
+	}
+
+	client := prometheus.Client{API: fakeAPI{results: func(query string) model.Matrix {
+		switch {
+		case strings.Contains(query, "response_total") && strings.Contains(query, "sum by (namespace"):
+			return append(matrix(2, gateway...), append(matrix(1, remote...), matrix(3, flat...)...)...)
+		case strings.Contains(query, "response_total") && strings.Contains(query, "sum by (dst_namespace"):
+			return matrix(2, gateway[6:]...)
+		default:
+			return model.Matrix{}
+		}
+	}}}
+
+	b, err := client.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := b.Node(context.Background(), graph.Resource{Name: "web", Namespace: "foo", Kind: graph.DeploymentKind})
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 3) {
+		assert.Equal(t, "west__bar__api__service", edges[0].Destination.ID())
+		assert.Equal(t, 2.0, edges[0].Destination.RequestVolume)
+		assert.Equal(t, "east__bar__db__service", edges[1].Destination.ID())
+		assert.Equal(t, "west__bar__cache__deployment", edges[2].Destination.ID())
+	}
+
+	api := graph.Resource{Name: "api", Namespace: "bar", Kind: graph.ServiceKind, Cluster: "west"}
+	assert.Len(t, b.DownstreamEdgesOf(context.Background(), b.Node(context.Background(), api)), 1)
+
+	local := graph.Resource{Name: "api", Namespace: "bar", Kind: graph.ServiceKind}
+	assert.Len(t, b.DownstreamEdgesOf(context.Background(), b.Node(context.Background(), local)), 0)
+}
+
+func Test_BuilderQuantiles(t *testing.T) {
+	edge := []string{
+		"namespace", "foo",
+		"workload_kind", "deployment",
+		"workload_name", "web",
+		"dst_namespace", "bar",
+		"dst_workload_kind", "deployment",
+		"dst_workload_name", "api",
+	}
+
+	var mu sync.Mutex
+
+	latencyQueries := []string{}
+	client := prometheus.Client{API: fakeAPI{results: func(query string) model.Matrix {
+		switch {
+		case strings.Contains(query, "response_latency_ms_bucket"):
+			mu.Lock()
+			defer mu.Unlock()
+
+			latencyQueries = append(latencyQueries, query)
+
+			return append(withQuantile(matrix(5, edge...), "0.5"), withQuantile(matrix(80, edge...), "0.99")...)
+		case strings.Contains(query, "response_total") && strings.Contains(query, "outbound"):
+			return matrix(10, edge...)
+		default:
+			return model.Matrix{}
+		}
+	}}}
+
+	b, err := client.NewBuilder().WithQuantiles([]float64{0.5, 0.99}).Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range latencyQueries {
+		assert.Contains(t, query, "histogram_quantile(\n\t\t\t0.5,")
+		assert.Contains(t, query, `"quantile", "0.99"`)
+	}
+
+	web := b.Node(context.Background(), graph.Resource{Name: "web", Namespace: "foo", Kind: graph.DeploymentKind})
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, graph.Latencies{0.5: 5, 0.99: 80}, edges[0].Latencies)
+	}
+}
+
+var errRangeQuery = errors.New("unexpected range query")
+
+// instantAPI answers instant queries only.
+type instantAPI struct {
+	fakeAPI
+}
+
+func (instantAPI) QueryRange(
+	ctx context.Context, query string, r prom.Range, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	return nil, nil, errRangeQuery
+}
+
+func Test_BuilderInstantQueries(t *testing.T) {
+	var mu sync.Mutex
+
+	queries := []string{}
+	client := prometheus.Client{Instant: true, API: instantAPI{fakeAPI{results: func(query string) model.Matrix {
+		mu.Lock()
+		defer mu.Unlock()
+
+		queries = append(queries, query)
+
+		return edgeQueries(query)
+	}}}}
+
+	b, err := client.NewBuilder().Build(context.Background(), 0, 3600000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range queries {
+		assert.Contains(t, query, "[3600s]")
+		assert.NotContains(t, query, "irate")
+	}
+
+	web := b.Node(context.Background(), graph.Resource{Name: "web", Namespace: "foo", Kind: graph.DeploymentKind})
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 2) {
+		assert.Equal(t, 10.0, edges[0].RequestRate)
+		assert.Equal(t, 0.5, edges[0].SuccessRate)
+	}
+}
+
+func Test_BuilderQueryTemplates(t *testing.T) {
+	_, err := prometheus.NewClient(prometheus.Config{
+		ReplayDir: "testdata/synthetic",
+		Queries:   prometheus.QueryTemplates{Volume: "sum by ({{.Missing}}) (x)"},
+	})
+	assert.True(t, errors.Is(err, prometheus.ErrInvalidTemplate))
+
+	client, err := prometheus.NewClient(prometheus.Config{
+		ReplayDir: "testdata/synthetic",
+		Labels:    `, cluster="east"`,
+		Queries: prometheus.QueryTemplates{
+			Edges: `sum by ({{.Grouping}}) ({{.Relabel "namespace_workload:response_total:rate5m{direction=\"outbound\"}"}})`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+
+	queries := []string{}
+	client.API = fakeAPI{results: func(query string) model.Matrix {
+		mu.Lock()
+		defer mu.Unlock()
+
+		queries = append(queries, query)
+
+		return model.Matrix{}
+	}}
+
+	if _, err := client.NewBuilder().Build(context.Background(), 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := 0
+
+	for _, query := range queries {
+		if strings.Contains(query, "namespace_workload:response_total:rate5m") {
+			recorded++
+
+			assert.Contains(t, query, "sum by (namespace, workload_kind, workload_name, dst_namespace")
+		} else {
+			assert.Contains(t, query, `cluster="east"`)
+		}
+	}
+
+	assert.Equal(t, 1, recorded)
+}
+
+func Test_BuilderRateWindow(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		client     prometheus.Client
+		from       int64
+		to         int64
+		rateWindow time.Duration
+		want       string
+	}{
+		{name: "four scrape intervals", client: prometheus.Client{ScrapeInterval: time.Minute}, to: 3600000, want: "[240s]"},
+		{name: "default scrape interval", to: 3600000, want: "[40s]"},
+		{name: "step and scrape interval", client: prometheus.Client{ScrapeInterval: time.Minute}, to: 86400000, want: "[780s]"},
+		{name: "override", to: 86400000, rateWindow: 5 * time.Minute, want: "[300s]"},
+		{name: "instant", client: prometheus.Client{Instant: true}, from: 60000, to: 3660000, want: "[3600s]"},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+
+			queries := []string{}
+			tt.client.API = fakeAPI{results: func(query string) model.Matrix {
+				mu.Lock()
+				defer mu.Unlock()
+
+				queries = append(queries, query)
+
+				return model.Matrix{}
+			}}
+
+			_, err := tt.client.NewBuilder().WithRateWindow(tt.rateWindow).Build(context.Background(), tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, query := range queries {
+				assert.Contains(t, query, tt.want)
+				assert.NotContains(t, query, "irate")
+			}
+		})
+	}
+}
+
+// stepAPI records the step of every range query.
+type stepAPI struct {
+	fakeAPI
+	mu    *sync.Mutex
+	steps *[]time.Duration
+}
+
+func (s stepAPI) QueryRange(
+	ctx context.Context, query string, r prom.Range, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	*s.steps = append(*s.steps, r.Step)
+
+	return model.Matrix{}, nil, nil
+}
+
+func Test_BuilderStep(t *testing.T) {
+	week := int64(7 * 24 * 3600 * 1000)
+
+	for _, tt := range []struct {
+		name   string
+		client prometheus.Client
+		to     int64
+		step   time.Duration
+		want   time.Duration
+	}{
+		{name: "minimum", to: 3600000, want: 30 * time.Second},
+		{name: "default max points", to: week, want: 84 * time.Minute},
+		{name: "max points", client: prometheus.Client{MaxPoints: 1000}, to: week, want: 604 * time.Second},
+		{name: "override", to: 3600000, step: time.Minute, want: time.Minute},
+		{name: "override over max points", to: week, step: time.Second, want: 84 * time.Minute},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			steps := []time.Duration{}
+			tt.client.API = stepAPI{mu: &sync.Mutex{}, steps: &steps}
+
+			_, err := tt.client.NewBuilder().WithStep(tt.step).Build(context.Background(), 0, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.NotEmpty(t, steps)
+
+			for _, step := range steps {
+				assert.Equal(t, tt.want, step)
+			}
+		})
+	}
+}
+
+var errQueryTimeout = errors.New("query timed out")
+
+// flakyAPI fails the queries matching fails and warns about every other one.
+type flakyAPI struct {
+	fakeAPI
+	fails func(query string) bool
+}
+
+func (f flakyAPI) QueryRange(
+	ctx context.Context, query string, r prom.Range, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	if f.fails(query) {
+		return nil, nil, errQueryTimeout
+	}
+
+	return f.results(query), prom.Warnings{"results truncated"}, nil
+}
+
+func Test_BuilderPartialResults(t *testing.T) {
+	client := prometheus.Client{API: flakyAPI{
+		fakeAPI: fakeAPI{results: edgeQueries},
+		fails: func(query string) bool {
+			return strings.Contains(query, "response_latency_ms_bucket")
+		},
+	}}
+
+	b, err := client.NewBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, b.Warnings(), "edges: results truncated")
+	assert.Contains(t, b.Warnings(), "latency unavailable: query timed out")
+
+	web := b.Node(context.Background(), graph.Resource{Name: "web", Namespace: "foo", Kind: graph.DeploymentKind})
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 2) {
+		assert.Equal(t, 10.0, edges[0].RequestRate)
+		assert.Equal(t, 0.5, edges[0].SuccessRate)
+		assert.Empty(t, edges[0].Latencies)
+	}
+
+	client.API = flakyAPI{
+		fakeAPI: fakeAPI{results: edgeQueries},
+		fails: func(query string) bool {
+			return !strings.Contains(query, "classification")
+		},
+	}
+
+	_, err = client.NewBuilder().Build(context.Background(), 0, 0)
+	assert.True(t, errors.Is(err, errQueryTimeout))
+}
@@ -0,0 +1,169 @@
+package prometheus
+
+import (
+	"linkerd-nodegraph/internal/graph"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// labelSeparator cannot appear in label values, so that keys joining them are
+// unambiguous.
+const labelSeparator = "\xff"
+
+// vectorIndex indexes the samples of a vector by the values of the labels
+// they are grouped by, in the order of the vector. The zero vectorIndex is
+// empty, as is the index of a nil vector, left by a failed query.
+type vectorIndex struct {
+	labels  []model.LabelName
+	samples map[string][]*model.Sample
+}
+
+func newVectorIndex(vector model.Vector, labels []model.LabelName) vectorIndex {
+	if vector == nil {
+		return vectorIndex{labels: labels}
+	}
+
+	index := vectorIndex{labels: labels, samples: make(map[string][]*model.Sample, len(vector))}
+
+	for _, sample := range vector {
+		key := index.key(sample.Metric)
+		index.samples[key] = append(index.samples[key], sample)
+	}
+
+	return index
+}
+
+// key joins the values of the labels of index in metric, missing labels
+// being empty.
+func (index vectorIndex) key(metric model.Metric) string {
+	values := make([]string, 0, len(index.labels))
+	for _, label := range index.labels {
+		values = append(values, string(metric[label]))
+	}
+
+	return strings.Join(values, labelSeparator)
+}
+
+// lookup returns the samples whose labels match metric.
+func (index vectorIndex) lookup(metric model.Metric) []*model.Sample {
+	if index.samples == nil {
+		return nil
+	}
+
+	return index.samples[index.key(metric)]
+}
+
+// value returns the value of the first sample whose labels match metric.
+func (index vectorIndex) value(metric model.Metric) model.SampleValue {
+	samples := index.lookup(metric)
+	if len(samples) == 0 {
+		return 0
+	}
+
+	return samples[0].Value
+}
+
+// successRate returns the success rate of the first sample whose labels
+// match metric, and whether it is known. A resource that got requests without
+// any sample is known to have failed them all, unless the success rates
+// could not be queried.
+func (index vectorIndex) successRate(metric model.Metric, requests float64) (float64, bool) {
+	samples := index.lookup(metric)
+	if len(samples) == 0 {
+		return 0, index.samples != nil && requests > 0
+	}
+
+	rate := float64(samples[0].Value)
+	if math.IsNaN(rate) {
+		return 0, false
+	}
+
+	return rate, true
+}
+
+// latencies returns the latencies of the samples whose labels match metric,
+// by quantile.
+func (index vectorIndex) latencies(metric model.Metric) graph.Latencies {
+	latencies := graph.Latencies{}
+
+	for _, sample := range index.lookup(metric) {
+		quantile, err := strconv.ParseFloat(string(sample.Metric[quantileLabel]), 64)
+		if err != nil {
+			continue
+		}
+
+		latencies[quantile] = float64(sample.Value)
+	}
+
+	return latencies
+}
+
+// resourceKey identifies a resource by its workload label values, undefined
+// kinds being looked up as deployments.
+type resourceKey struct {
+	namespace model.LabelValue
+	kind      model.LabelValue
+	name      model.LabelValue
+	cluster   model.LabelValue
+}
+
+// metricResourceKey returns the key of the workload labels of metric,
+// prefixed by prefix.
+func metricResourceKey(metric model.Metric, prefix string) resourceKey {
+	return resourceKey{
+		namespace: metric[model.LabelName(prefix)+namespaceLabel],
+		kind:      metric[model.LabelName(prefix)+workloadKindLabel],
+		name:      metric[model.LabelName(prefix)+workloadNameLabel],
+		cluster:   metric[model.LabelName(prefix)+targetClusterLabel],
+	}
+}
+
+func resourceKeyOf(resource graph.Resource) resourceKey {
+	return resourceKey{
+		namespace: model.LabelValue(resource.Namespace),
+		kind:      kindValue(resource.Kind),
+		name:      model.LabelValue(resource.Name),
+		cluster:   model.LabelValue(resource.Cluster),
+	}
+}
+
+// edgeIndex indexes the valid samples of an edge vector by source and by
+// destination, in the order of the vector. The zero edgeIndex is empty.
+type edgeIndex struct {
+	bySource      map[resourceKey][]*model.Sample
+	byDestination map[resourceKey][]*model.Sample
+}
+
+func newEdgeIndex(vector model.Vector) edgeIndex {
+	index := edgeIndex{
+		bySource:      map[resourceKey][]*model.Sample{},
+		byDestination: map[resourceKey][]*model.Sample{},
+	}
+
+	for _, sample := range vector {
+		if !validEdgeSample(*sample) {
+			continue
+		}
+
+		source := metricResourceKey(sample.Metric, "")
+		index.bySource[source] = append(index.bySource[source], sample)
+
+		destination := metricResourceKey(sample.Metric, dstPrefix)
+		index.byDestination[destination] = append(index.byDestination[destination], sample)
+	}
+
+	return index
+}
+
+// from returns the samples of the edges going out of resource.
+func (index edgeIndex) from(resource graph.Resource) []*model.Sample {
+	return index.bySource[resourceKeyOf(resource)]
+}
+
+// to returns the samples of the edges coming into resource.
+func (index edgeIndex) to(resource graph.Resource) []*model.Sample {
+	return index.byDestination[resourceKeyOf(resource)]
+}
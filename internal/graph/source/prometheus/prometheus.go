@@ -3,8 +3,11 @@ package prometheus
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"linkerd-nodegraph/internal/graph"
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/api"
 	prom "github.com/prometheus/client_golang/api/prometheus/v1"
@@ -12,12 +15,24 @@ import (
 )
 
 type promAPI interface {
+	Query(ctx context.Context, query string, ts time.Time, opts ...prom.Option) (model.Value, prom.Warnings, error)
 	QueryRange(ctx context.Context, query string, r prom.Range, opts ...prom.Option) (model.Value, prom.Warnings, error)
 }
 
 type Client struct {
 	API    promAPI
 	Labels string
+	// Instant makes the builders issue a single instant query over the whole
+	// time range instead of averaging range queries.
+	Instant bool
+	// ScrapeInterval is the scrape interval of Prometheus, which bounds the
+	// rate window of range queries. DefaultScrapeInterval when zero.
+	ScrapeInterval time.Duration
+	// MaxPoints bounds the number of points per series of range queries.
+	// DefaultMaxPoints when zero.
+	MaxPoints int
+
+	queryTemplates *queryTemplates
 }
 
 type roundTripper struct {
@@ -30,9 +45,49 @@ type Config struct {
 	Labels    string
 	Headers   map[string]string
 	TLSConfig *tls.Config
+	// RecordDir, when set, is where every response received is recorded.
+	RecordDir string
+	// ReplayDir, when set, is where responses are replayed from instead of
+	// querying Prometheus.
+	ReplayDir string
+	Instant   bool
+	// ScrapeInterval is the scrape interval of Prometheus.
+	ScrapeInterval time.Duration
+	// MaxPoints bounds the number of points per series of range queries.
+	MaxPoints int
+	// Queries override the default query templates.
+	Queries QueryTemplates
 }
 
+var ErrRecordAndReplay = errors.New("cannot both record and replay responses")
+
 func NewClient(config Config) (*Client, error) {
+	if config.Labels != "" && config.Labels != " " {
+		config.Labels = "," + config.Labels
+	} else {
+		config.Labels = " "
+	}
+
+	if config.RecordDir != "" && config.ReplayDir != "" {
+		return nil, ErrRecordAndReplay
+	}
+
+	templates, err := newQueryTemplates(config.Queries)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ReplayDir != "" {
+		return &Client{
+			API:            NewReplayAPI(config.ReplayDir),
+			Labels:         config.Labels,
+			Instant:        config.Instant,
+			ScrapeInterval: config.ScrapeInterval,
+			MaxPoints:      config.MaxPoints,
+			queryTemplates: templates,
+		}, nil
+	}
+
 	c, err := api.NewClient(api.Config{
 		Address: config.Address,
 		Client: &http.Client{
@@ -48,18 +103,51 @@ func NewClient(config Config) (*Client, error) {
 		return nil, fmt.Errorf("error creating prometheus client: %w", err)
 	}
 
-	if config.Labels != "" && config.Labels != " " {
-		config.Labels = "," + config.Labels
-	} else {
-		config.Labels = " "
+	var api promAPI = prom.NewAPI(c)
+	if config.RecordDir != "" {
+		api = NewRecordingAPI(api, config.RecordDir)
 	}
 
 	return &Client{
-		API:    prom.NewAPI(c),
-		Labels: config.Labels,
+		API:            api,
+		Labels:         config.Labels,
+		Instant:        config.Instant,
+		ScrapeInterval: config.ScrapeInterval,
+		MaxPoints:      config.MaxPoints,
+		queryTemplates: templates,
 	}, nil
 }
 
+// Snapshot builds the snapshot described by query.
+func (prometheus Client) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	switch query.View {
+	case graph.ServiceView:
+		b, err := prometheus.NewServiceBuilder().
+			WithQuantiles(query.Quantiles).
+			WithRateWindow(query.RateWindow).
+			WithStep(query.Step).
+			Build(ctx, query.From, query.To)
+		if err != nil {
+			return nil, err
+		}
+
+		return b, nil
+	case graph.WorkloadView:
+		b, err := prometheus.NewBuilder().
+			WithQuantiles(query.Quantiles).
+			WithRateWindow(query.RateWindow).
+			WithStep(query.Step).
+			Build(ctx, query.From, query.To)
+		if err != nil {
+			return nil, err
+		}
+
+		return b, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", graph.ErrUnsupportedView, query.View)
+	}
+}
+
 func (t *roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 	for k, v := range t.headers {
 		r.Header.Set(k, v)
@@ -0,0 +1,178 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+var ErrNotRecorded = errors.New("no recording for query")
+
+// rangeSelector matches the range selectors of queries, such as [40s] or
+// [1h:30s], which depend on the time range and step queried.
+var rangeSelector = regexp.MustCompile(`\[[0-9a-z:]+\]`)
+
+// recording is the content of a recorded Query or QueryRange call. Start, End
+// and Step are set for range queries, and Time for instant ones.
+type recording struct {
+	Query  string       `json:"query"`
+	Start  *time.Time   `json:"start,omitempty"`
+	End    *time.Time   `json:"end,omitempty"`
+	Step   string       `json:"step,omitempty"`
+	Matrix model.Matrix `json:"matrix,omitempty"`
+	Time   *time.Time   `json:"time,omitempty"`
+	Vector model.Vector `json:"vector,omitempty"`
+}
+
+// recordingPath returns the path of the recording of query in dir, with an
+// instant suffix for instant queries. Queries are identified by their content
+// regardless of whitespace and of their range selectors, so a recording can
+// be replayed over any time range and step.
+func recordingPath(dir string, query string, instant bool) string {
+	normalized := rangeSelector.ReplaceAllString(strings.Join(strings.Fields(query), " "), "[]")
+	sum := sha256.Sum256([]byte(normalized))
+
+	name := hex.EncodeToString(sum[:8])
+	if instant {
+		name += ".instant"
+	}
+
+	return filepath.Join(dir, name+".json")
+}
+
+// RecordingAPI forwards queries to API and writes every matrix or vector
+// received to Dir, one file per query.
+type RecordingAPI struct {
+	API promAPI
+	Dir string
+}
+
+func NewRecordingAPI(api promAPI, dir string) *RecordingAPI {
+	return &RecordingAPI{API: api, Dir: dir}
+}
+
+func (r *RecordingAPI) QueryRange(
+	ctx context.Context, query string, timeRange prom.Range, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	res, warn, err := r.API.QueryRange(ctx, query, timeRange, opts...)
+	if err != nil {
+		return res, warn, err //nolint:wrapcheck
+	}
+
+	matrix, ok := res.(model.Matrix)
+	if !ok {
+		return res, warn, nil
+	}
+
+	err = r.write(recordingPath(r.Dir, query, false), recording{
+		Query:  query,
+		Start:  &timeRange.Start,
+		End:    &timeRange.End,
+		Step:   timeRange.Step.String(),
+		Matrix: matrix,
+	})
+	if err != nil {
+		return nil, warn, err
+	}
+
+	return res, warn, nil
+}
+
+func (r *RecordingAPI) Query(
+	ctx context.Context, query string, ts time.Time, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	res, warn, err := r.API.Query(ctx, query, ts, opts...)
+	if err != nil {
+		return res, warn, err //nolint:wrapcheck
+	}
+
+	vector, ok := res.(model.Vector)
+	if !ok {
+		return res, warn, nil
+	}
+
+	if err := r.write(recordingPath(r.Dir, query, true), recording{Query: query, Time: &ts, Vector: vector}); err != nil {
+		return nil, warn, err
+	}
+
+	return res, warn, nil
+}
+
+func (r *RecordingAPI) write(path string, rec recording) error {
+	content, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding recording: %w", err)
+	}
+
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating recording directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("error writing recording: %w", err)
+	}
+
+	return nil
+}
+
+// ReplayAPI serves the matrices and vectors recorded in Dir by a
+// RecordingAPI, whatever the time asked for.
+type ReplayAPI struct {
+	Dir string
+}
+
+func NewReplayAPI(dir string) *ReplayAPI {
+	return &ReplayAPI{Dir: dir}
+}
+
+func (r *ReplayAPI) QueryRange(
+	ctx context.Context, query string, timeRange prom.Range, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	rec, err := r.read(recordingPath(r.Dir, query, false))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rec.Matrix, nil, nil
+}
+
+func (r *ReplayAPI) Query(
+	ctx context.Context, query string, ts time.Time, opts ...prom.Option,
+) (model.Value, prom.Warnings, error) {
+	rec, err := r.read(recordingPath(r.Dir, query, true))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rec.Vector, nil, nil
+}
+
+func (r *ReplayAPI) read(path string) (*recording, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s not found in %s", ErrNotRecorded, filepath.Base(path), r.Dir)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading recording: %w", err)
+	}
+
+	var rec recording
+
+	if err := json.Unmarshal(content, &rec); err != nil {
+		return nil, fmt.Errorf("error decoding recording: %w", err)
+	}
+
+	return &rec, nil
+}
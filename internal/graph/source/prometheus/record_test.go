@@ -0,0 +1,175 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "record the testdata fixtures again")
+
+const syntheticFixtures = "testdata/synthetic"
+
+// series returns a matrix of one series labelled by labels, with a sample
+// per value.
+func series(labels []string, values ...float64) model.Matrix {
+	m := matrix(0, labels...)
+	m[0].Values = []model.SamplePair{}
+
+	for i, value := range values {
+		m[0].Values = append(m[0].Values, model.SamplePair{
+			Timestamp: model.Time(int64(i) * 30000),
+			Value:     model.SampleValue(value),
+		})
+	}
+
+	return m
+}
+
+// syntheticQueries answers the queries of the builder with made-up traffic
+// shaped like the emojivoto demo application, where voting calls an unmeshed
+// database. The synthetic fixtures are generated from it with -update.
+func syntheticQueries(query string) model.Matrix {
+	workload := func(name string) []string {
+		return []string{"namespace", "emojivoto", "workload_kind", "deployment", "workload_name", name}
+	}
+	destination := func(kind string, name string) []string {
+		return []string{"dst_namespace", "emojivoto", "dst_workload_kind", kind, "dst_workload_name", name}
+	}
+	edge := func(source string, kind string, name string) []string {
+		return append(workload(source), destination(kind, name)...)
+	}
+	nodes := func(web, emoji, voting []float64) model.Matrix {
+		m := series(workload("web"), web...)
+		m = append(m, series(workload("emoji"), emoji...)...)
+
+		return append(m, series(workload("voting"), voting...)...)
+	}
+	edges := func(bot, emoji, voting, postgres []float64) model.Matrix {
+		m := series(edge("vote-bot", "deployment", "web"), bot...)
+		m = append(m, series(edge("web", "deployment", "emoji"), emoji...)...)
+		m = append(m, series(edge("web", "deployment", "voting"), voting...)...)
+
+		return append(m, series(edge("voting", "unmeshed", "postgres"), postgres...)...)
+	}
+
+	inbound := strings.Contains(query, `direction="inbound"`)
+	outboundEdge := strings.Contains(query, "workload_name, dst_namespace")
+	latency := strings.Contains(query, "response_latency_ms_bucket")
+	success := strings.Contains(query, `classification="success"`)
+
+	switch {
+	case inbound && latency:
+		return withQuantile(nodes([]float64{40, 50}, []float64{5, 5}, []float64{10, 14}), "0.95")
+	case inbound && success:
+		return nodes([]float64{0.9, 0.94}, []float64{1, 1}, []float64{0.8, 0.88})
+	case inbound:
+		return nodes([]float64{11, 13}, []float64{6, 6}, []float64{5, 7})
+	case outboundEdge && latency:
+		return withQuantile(
+			edges([]float64{47, 47}, []float64{6, 6}, []float64{12, 16}, []float64{3, 5}), "0.95")
+	case outboundEdge && success:
+		return edges([]float64{0.9, 0.94}, []float64{1, 1}, []float64{0.8, 0.88}, []float64{1, 0.98})
+	case outboundEdge:
+		return edges([]float64{12, 12}, []float64{6, 6}, []float64{6, 6}, []float64{4, 4})
+	case latency:
+		return withQuantile(series(destination("unmeshed", "postgres"), 3, 5), "0.95")
+	case success:
+		return series(destination("unmeshed", "postgres"), 1, 0.98)
+	default:
+		return series(destination("unmeshed", "postgres"), 4, 4)
+	}
+}
+
+func TestRecordingAPI(t *testing.T) {
+	dir := t.TempDir()
+
+	recording := prometheus.Client{API: prometheus.NewRecordingAPI(fakeAPI{results: edgeQueries}, dir)}
+
+	recorded, err := recording.NewBuilder().Build(context.Background(), 0, 60000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replay := prometheus.Client{API: prometheus.NewReplayAPI(dir)}
+
+	// Replay over a day, with another rate window and step than recorded.
+	replayed, err := replay.NewBuilder().Build(context.Background(), 120000, 86400000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, recorded.Resources(context.Background()), replayed.Resources(context.Background()))
+
+	for _, resource := range recorded.Resources(context.Background()) {
+		node := recorded.Node(context.Background(), resource)
+		assert.Equal(t, node, replayed.Node(context.Background(), resource))
+		assert.Equal(t,
+			recorded.EdgesOf(context.Background(), node),
+			replayed.EdgesOf(context.Background(), node),
+		)
+	}
+
+	_, err = replay.NewServiceBuilder().Build(context.Background(), 0, 60000)
+	assert.True(t, errors.Is(err, prometheus.ErrNotRecorded))
+}
+
+func Test_BuilderReplay(t *testing.T) {
+	if *update {
+		recording := prometheus.Client{
+			API:    prometheus.NewRecordingAPI(fakeAPI{results: syntheticQueries}, syntheticFixtures),
+			Labels: " ",
+		}
+
+		if _, err := recording.NewBuilder().Build(context.Background(), 0, 60000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, err := prometheus.NewClient(prometheus.Config{ReplayDir: syntheticFixtures})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := client.NewBuilder().Build(context.Background(), 0, 60000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := b.Node(context.Background(), graph.Resource{Name: "web", Namespace: "emojivoto", Kind: graph.DeploymentKind})
+	assert.Equal(t, 12.0, web.RequestVolume)
+	assert.InDelta(t, 0.92, web.SuccessRate, 1e-9)
+	assert.Equal(t, 45.0, web.Latencies[0.95])
+
+	edges := b.UpstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 2) {
+		assert.Equal(t, "emojivoto__emoji__deployment", edges[0].Destination.ID())
+		assert.Equal(t, "emojivoto__voting__deployment", edges[1].Destination.ID())
+		assert.Equal(t, 6.0, edges[1].RequestRate)
+		assert.InDelta(t, 0.84, edges[1].SuccessRate, 1e-9)
+		assert.Equal(t, 14.0, edges[1].Latencies[0.95])
+	}
+
+	edges = b.DownstreamEdgesOf(context.Background(), web)
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, "emojivoto__vote-bot__deployment", edges[0].Source.ID())
+	}
+
+	voting := b.Node(context.Background(), graph.Resource{Name: "voting", Namespace: "emojivoto", Kind: graph.DeploymentKind})
+
+	edges = b.UpstreamEdgesOf(context.Background(), voting)
+	if assert.Len(t, edges, 1) {
+		postgres := edges[0].Destination
+		assert.Equal(t, "emojivoto__postgres__unmeshed", postgres.ID())
+		assert.Equal(t, 4.0, postgres.RequestVolume)
+		assert.InDelta(t, 0.99, postgres.SuccessRate, 1e-9)
+		assert.Equal(t, 4.0, postgres.Latencies[0.95])
+	}
+}
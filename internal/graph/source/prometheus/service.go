@@ -0,0 +1,348 @@
+package prometheus
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// memberLabels identify a workload behind a Service in the members vector.
+var memberLabels = []model.LabelName{
+	dstNamespaceLabel,
+	dstServiceLabel,
+	dstWorkloadKindLabel,
+	dstWorkloadNameLabel,
+}
+
+// ServiceBuilder builds a graph of Kubernetes Services, merging the workloads
+// behind the same Service into a single node. Destinations without a Service
+// are named after their authority, and clients that are not behind any
+// Service are kept as workloads.
+type ServiceBuilder struct {
+	client              *Client
+	window              rangeWindow
+	rateWindow          time.Duration
+	step                time.Duration
+	quantiles           []float64
+	vectorMembers       model.Vector
+	vectorSuccessRate   model.Vector
+	vectorLatency       model.Vector
+	vectorRequestVolume model.Vector
+	vectorEdges         model.Vector
+	vectorEdgeSuccess   model.Vector
+	vectorEdgeLatency   model.Vector
+
+	// Indices of the vectors, set once built.
+	indexSuccessRate   vectorIndex
+	indexLatency       vectorIndex
+	indexRequestVolume vectorIndex
+	indexEdges         edgeIndex
+	indexEdgeSuccess   vectorIndex
+	indexEdgeLatency   vectorIndex
+
+	// warnings tell why some stats may be missing.
+	warnings []string
+
+	// services maps a workload to the Services in front of it and workloads
+	// a Service to the workloads behind it.
+	services  map[graph.Resource][]graph.Resource
+	workloads map[graph.Resource][]graph.Resource
+}
+
+func (prometheus Client) NewServiceBuilder() *ServiceBuilder {
+	return &ServiceBuilder{
+		client:              &prometheus,
+		vectorMembers:       nil,
+		vectorSuccessRate:   nil,
+		vectorLatency:       nil,
+		vectorRequestVolume: nil,
+		vectorEdges:         nil,
+		vectorEdgeSuccess:   nil,
+		vectorEdgeLatency:   nil,
+		services:            map[graph.Resource][]graph.Resource{},
+		workloads:           map[graph.Resource][]graph.Resource{},
+	}
+}
+
+// WithQuantiles sets the latency quantiles computed by Build, the default
+// quantile when empty.
+func (builder *ServiceBuilder) WithQuantiles(quantiles []float64) *ServiceBuilder {
+	builder.quantiles = quantiles
+
+	return builder
+}
+
+// WithRateWindow sets the window of the rates computed by Build, derived from
+// the time range and the scrape interval when zero.
+func (builder *ServiceBuilder) WithRateWindow(window time.Duration) *ServiceBuilder {
+	builder.rateWindow = window
+
+	return builder
+}
+
+// WithStep sets the resolution of the range queries of Build, derived from
+// the time range when zero.
+func (builder *ServiceBuilder) WithStep(step time.Duration) *ServiceBuilder {
+	builder.step = step
+
+	return builder
+}
+
+func (builder *ServiceBuilder) Build(ctx context.Context, from int64, to int64) (*ServiceBuilder, error) {
+	builder.window = builder.client.window(from, to, builder.rateWindow, builder.step)
+
+	r := builder.client.renderer(builder.window, builder.quantiles)
+	templates := builder.client.templates()
+
+	queries := []vectorQuery{
+		{
+			name:     "service members",
+			query:    r.render(templates.volume, outbound, memberLabels, relabelMember),
+			target:   &builder.vectorMembers,
+			required: true,
+		},
+		{
+			name:     "service edges",
+			query:    r.render(templates.edges, outbound, edgeLabels, relabelServiceEdge),
+			target:   &builder.vectorEdges,
+			required: true,
+		},
+		{
+			name:   "service edge success rate",
+			query:  r.render(templates.successRate, outbound, edgeLabels, relabelServiceEdge),
+			target: &builder.vectorEdgeSuccess,
+		},
+		{
+			name:   "service edge latency",
+			query:  r.renderLatency(outbound, edgeLabels, relabelServiceEdge),
+			target: &builder.vectorEdgeLatency,
+		},
+		{
+			name:   "service success rate",
+			query:  r.render(templates.successRate, outbound, destinationLabels, relabelService),
+			target: &builder.vectorSuccessRate,
+		},
+		{
+			name:   "service latency",
+			query:  r.renderLatency(outbound, destinationLabels, relabelService),
+			target: &builder.vectorLatency,
+		},
+		{
+			name:   "service volume",
+			query:  r.render(templates.volume, outbound, destinationLabels, relabelService),
+			target: &builder.vectorRequestVolume,
+		},
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	warnings, err := buildVectors(ctx, from, to, builder.window.step, builder.client, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	builder.warnings = warnings
+
+	stitchMirrors(
+		builder.vectorEdges, builder.vectorEdgeSuccess, builder.vectorEdgeLatency,
+		builder.vectorSuccessRate, builder.vectorLatency, builder.vectorRequestVolume,
+	)
+
+	builder.indexSuccessRate = newVectorIndex(builder.vectorSuccessRate, destinationLabels)
+	builder.indexLatency = newVectorIndex(builder.vectorLatency, destinationLabels)
+	builder.indexRequestVolume = newVectorIndex(builder.vectorRequestVolume, destinationLabels)
+	builder.indexEdges = newEdgeIndex(builder.vectorEdges)
+	builder.indexEdgeSuccess = newVectorIndex(builder.vectorEdgeSuccess, edgeLabels)
+	builder.indexEdgeLatency = newVectorIndex(builder.vectorEdgeLatency, edgeLabels)
+
+	for _, sample := range builder.vectorMembers {
+		service, workload, ok := memberSample(sample.Metric)
+		if !ok {
+			continue
+		}
+
+		builder.services[workload] = append(builder.services[workload], service)
+		builder.workloads[service] = append(builder.workloads[service], workload)
+	}
+
+	return builder, nil
+}
+
+// Size estimates the memory used by the builder, in bytes.
+func (builder *ServiceBuilder) Size() int {
+	return vectorsSize(
+		builder.vectorMembers, builder.vectorSuccessRate, builder.vectorLatency, builder.vectorRequestVolume,
+		builder.vectorEdges, builder.vectorEdgeSuccess, builder.vectorEdgeLatency,
+	)
+}
+
+// Warnings tells about the queries that failed, whose stats are missing, and
+// about the warnings of Prometheus.
+func (builder *ServiceBuilder) Warnings() []string {
+	return builder.warnings
+}
+
+// memberSample returns the Service and the workload behind it of a sample of
+// the members vector, if both are known.
+func memberSample(metric model.Metric) (graph.Resource, graph.Resource, bool) {
+	workload := sampleResource(metric, dstPrefix)
+	if metric[dstServiceLabel] == "" || workload.Kind == graph.UndefinedKind {
+		return graph.Resource{}, graph.Resource{}, false
+	}
+
+	service := graph.Resource{
+		Namespace: string(metric[dstNamespaceLabel]),
+		Name:      string(metric[dstServiceLabel]),
+		Kind:      graph.ServiceKind,
+	}
+
+	return service, workload, true
+}
+
+// relabelServiceEdge is relabelSource followed by relabelService.
+func relabelServiceEdge(expr string) string {
+	return relabelService(relabelSource(expr))
+}
+
+// Node returns the graph.Node associated with resource. Services and external
+// resources take their stats from the outbound side of their clients, while
+// the workloads that are only clients have none.
+func (builder ServiceBuilder) Node(ctx context.Context, resource graph.Resource) *graph.Node {
+	if resource.Kind != graph.ServiceKind && resource.Kind != graph.ExternalKind {
+		return &graph.Node{Resource: resource}
+	}
+
+	metric := model.Metric{
+		dstNamespaceLabel:     model.LabelValue(resource.Namespace),
+		dstWorkloadKindLabel:  kindValue(resource.Kind),
+		dstWorkloadNameLabel:  model.LabelValue(resource.Name),
+		dstTargetClusterLabel: model.LabelValue(resource.Cluster),
+	}
+
+	volume := float64(builder.indexRequestVolume.value(metric))
+	successRate, known := builder.indexSuccessRate.successRate(metric, volume)
+
+	return &graph.Node{
+		Resource:         resource,
+		SuccessRate:      successRate,
+		SuccessRateKnown: known,
+		RequestVolume:    volume,
+		Latencies:        builder.indexLatency.latencies(metric),
+	}
+}
+
+func (builder ServiceBuilder) edge(source *graph.Node, destination *graph.Node, sample *model.Sample) graph.Edge {
+	successRate, known := builder.indexEdgeSuccess.successRate(sample.Metric, float64(sample.Value))
+
+	return graph.Edge{
+		Source:           source,
+		Destination:      destination,
+		RequestRate:      float64(sample.Value),
+		SuccessRate:      successRate,
+		SuccessRateKnown: known,
+		Latencies:        builder.indexEdgeLatency.latencies(sample.Metric),
+	}
+}
+
+// clients returns the workloads sending traffic on behalf of resource.
+func (builder ServiceBuilder) clients(resource graph.Resource) []graph.Resource {
+	switch resource.Kind {
+	case graph.ServiceKind:
+		return builder.workloads[resource]
+	case graph.ExternalKind:
+		return nil
+	default:
+		return []graph.Resource{resource}
+	}
+}
+
+// sources returns the resources standing for workload in the graph: the
+// Services in front of it, or itself if there is none.
+func (builder ServiceBuilder) sources(workload graph.Resource) []graph.Resource {
+	if services, ok := builder.services[workload]; ok {
+		return services
+	}
+
+	return []graph.Resource{workload}
+}
+
+func (builder ServiceBuilder) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, workload := range builder.clients(node.Resource) {
+		for _, sample := range builder.indexEdges.from(workload) {
+			resource := sampleResource(sample.Metric, dstPrefix)
+			if resource.Kind == graph.UndefinedKind {
+				continue
+			}
+
+			edgeNode := builder.Node(ctx, resource)
+
+			edges = append(edges, builder.edge(node, edgeNode, sample))
+		}
+	}
+
+	return graph.MergeEdges(edges)
+}
+
+func (builder ServiceBuilder) DownstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, sample := range builder.indexEdges.to(node.Resource) {
+		workload := sampleResource(sample.Metric, "")
+		if workload.Kind == graph.UndefinedKind {
+			continue
+		}
+
+		for _, resource := range builder.sources(workload) {
+			edgeNode := builder.Node(ctx, resource)
+
+			edges = append(edges, builder.edge(edgeNode, node, sample))
+		}
+	}
+
+	return graph.MergeEdges(edges)
+}
+
+// Resources returns every resource seen in the service edges vector.
+func (builder ServiceBuilder) Resources(ctx context.Context) []graph.Resource {
+	resources := []graph.Resource{}
+	seen := map[graph.Resource]bool{}
+
+	add := func(resource graph.Resource) {
+		if resource.Kind == graph.UndefinedKind || seen[resource] {
+			return
+		}
+
+		seen[resource] = true
+		resources = append(resources, resource)
+	}
+
+	for _, sample := range builder.vectorEdges {
+		if !validEdgeSample(*sample) {
+			continue
+		}
+
+		workload := sampleResource(sample.Metric, "")
+		if workload.Kind != graph.UndefinedKind {
+			for _, resource := range builder.sources(workload) {
+				add(resource)
+			}
+		}
+
+		add(sampleResource(sample.Metric, dstPrefix))
+	}
+
+	return resources
+}
+
+func (builder ServiceBuilder) EdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+	edges = append(edges, builder.UpstreamEdgesOf(ctx, node)...)
+	edges = append(edges, builder.DownstreamEdgesOf(ctx, node)...)
+
+	return edges
+}
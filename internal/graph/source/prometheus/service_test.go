@@ -0,0 +1,79 @@
+package prometheus_test
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func serviceQueries(query string) model.Matrix {
+	member := func(workload string) []string {
+		return []string{
+			"dst_namespace", "bar",
+			"dst_service", "api",
+			"dst_workload_kind", "deployment",
+			"dst_workload_name", workload,
+		}
+	}
+	edge := func(workload string) []string {
+		return []string{
+			"namespace", "bar",
+			"workload_kind", "deployment",
+			"workload_name", workload,
+			"dst_namespace", "baz",
+			"dst_workload_kind", "service",
+			"dst_workload_name", "db",
+		}
+	}
+	service := []string{
+		"dst_namespace", "bar",
+		"dst_workload_kind", "service",
+		"dst_workload_name", "api",
+	}
+
+	switch {
+	case strings.Contains(query, "sum by (dst_namespace, dst_service,"):
+		return append(matrix(1, member("api")...), matrix(1, member("api-canary")...)...)
+	case strings.Contains(query, "sum by (dst_namespace, dst_workload_kind"):
+		return matrix(4, service...)
+	case strings.Contains(query, "response_latency_ms_bucket"):
+		return withQuantile(append(matrix(10, edge("api")...), matrix(30, edge("api-canary")...)...), "0.95")
+	case strings.Contains(query, "classification=\"success\""):
+		return append(matrix(1, edge("api")...), matrix(0.5, edge("api-canary")...)...)
+	default:
+		return append(matrix(3, edge("api")...), matrix(1, edge("api-canary")...)...)
+	}
+}
+
+func Test_ServiceBuilderMergesWorkloads(t *testing.T) {
+	client := prometheus.Client{API: fakeAPI{results: serviceQueries}}
+
+	b, err := client.NewServiceBuilder().Build(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := b.Node(context.Background(), graph.Resource{Name: "api", Namespace: "bar", Kind: graph.ServiceKind})
+	assert.Equal(t, 4.0, api.RequestVolume)
+
+	edges := b.UpstreamEdgesOf(context.Background(), api)
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, "baz__db__service", edges[0].Destination.ID())
+		assert.Equal(t, 4.0, edges[0].RequestRate)
+		assert.Equal(t, 0.875, edges[0].SuccessRate)
+		assert.Equal(t, 15.0, edges[0].Latencies[0.95])
+	}
+
+	db := b.Node(context.Background(), graph.Resource{Name: "db", Namespace: "baz", Kind: graph.ServiceKind})
+
+	edges = b.DownstreamEdgesOf(context.Background(), db)
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, "bar__api__service", edges[0].Source.ID())
+		assert.Equal(t, 4.0, edges[0].RequestRate)
+	}
+}
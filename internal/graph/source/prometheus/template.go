@@ -0,0 +1,269 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/prometheus/common/model"
+)
+
+var ErrInvalidTemplate = errors.New("invalid query template")
+
+const (
+	// Default query templates, see QueryTemplates.
+	defaultSuccessRateTemplate = `
+	sum by ({{.Grouping}}) (
+		{{.Success}}
+	) /
+	sum by ({{.Grouping}}) (
+		{{.Responses}}
+	) >= 0`
+
+	// Indented to be rendered within queryFormatQuantile.
+	defaultLatencyTemplate = `histogram_quantile(
+			{{.Quantile}},
+			sum by (le, {{.Grouping}}) (
+				{{.Buckets}}
+			)
+		)`
+
+	defaultVolumeTemplate = `
+	sum by ({{.Grouping}}) (
+		{{.Requests}}
+	)`
+
+	// Range formats take the additional filter labels and the rate window.
+	rangeFormatInboundSuccess    = `rate(response_total{classification="success", direction="inbound", namespace!="" %[1]s}[%[2]s])`
+	rangeFormatInboundResponses  = `rate(response_total{direction="inbound", namespace!="" %[1]s}[%[2]s])`
+	rangeFormatInboundLatency    = `rate(response_latency_ms_bucket{direction="inbound" %[1]s}[%[2]s])`
+	rangeFormatInboundRequests   = `rate(request_total{direction="inbound" %[1]s}[%[2]s])`
+	rangeFormatOutboundSuccess   = `rate(response_total{classification="success", direction="outbound", namespace!="" %[1]s}[%[2]s])`
+	rangeFormatOutboundResponses = `rate(response_total{direction="outbound", namespace!="" %[1]s}[%[2]s])`
+	rangeFormatOutboundLatency   = `rate(response_latency_ms_bucket{direction="outbound", namespace!="" %[1]s}[%[2]s])`
+	rangeFormatOutboundRequests  = `rate(response_total{direction="outbound", namespace!="" %[1]s}[%[2]s])`
+
+	// 1: latency query, 2: quantile
+	queryFormatQuantile = `
+	label_replace(
+		%[1]s,
+		"quantile", "%[2]s", "", ""
+	)`
+
+	inbound  = "inbound"
+	outbound = "outbound"
+)
+
+// QueryTemplates override the Go templates rendering the queries of the
+// builders, the default ones when empty. Templates receive a QueryData.
+type QueryTemplates struct {
+	// SuccessRate renders the ratio of successful responses.
+	SuccessRate string
+	// Latency renders the latency at a quantile, in milliseconds.
+	Latency string
+	// Volume renders the request rate of nodes.
+	Volume string
+	// Edges renders the request rate of edges.
+	Edges string
+}
+
+// QueryData is given to query templates. The series are relabelled so that
+// they carry the grouping labels.
+type QueryData struct {
+	// Labels are the extra filter labels of the client, starting with a
+	// comma when not blank.
+	Labels string
+	// Window is the range of rates, such as 120s.
+	Window string
+	// Grouping are the labels to sum by, comma separated.
+	Grouping string
+	// Direction is inbound for the stats of workloads seen by themselves and
+	// outbound for the stats seen by their clients.
+	Direction string
+	// Quantile is the latency quantile, such as 0.95, in latency templates.
+	Quantile string
+
+	// Success, Responses, Requests and Buckets are the default series of
+	// successful responses, responses, requests and latency buckets.
+	Success   string
+	Responses string
+	Requests  string
+	Buckets   string
+
+	relabel func(string) string
+}
+
+// Relabel wraps expr so that its series carry the grouping labels.
+func (d QueryData) Relabel(expr string) string {
+	return d.relabel(expr)
+}
+
+// rangeFormats are the range formats of the series seen from each direction.
+var rangeFormats = map[string]struct {
+	success   string
+	responses string
+	requests  string
+	buckets   string
+}{
+	inbound: {
+		success:   rangeFormatInboundSuccess,
+		responses: rangeFormatInboundResponses,
+		requests:  rangeFormatInboundRequests,
+		buckets:   rangeFormatInboundLatency,
+	},
+	outbound: {
+		success:   rangeFormatOutboundSuccess,
+		responses: rangeFormatOutboundResponses,
+		requests:  rangeFormatOutboundRequests,
+		buckets:   rangeFormatOutboundLatency,
+	},
+}
+
+type queryTemplates struct {
+	successRate *template.Template
+	latency     *template.Template
+	volume      *template.Template
+	edges       *template.Template
+}
+
+var defaultQueryTemplates = func() *queryTemplates {
+	templates, err := newQueryTemplates(QueryTemplates{})
+	if err != nil {
+		panic(err)
+	}
+
+	return templates
+}()
+
+// newQueryTemplates parses the overrides of t, falling back to the default
+// templates, and checks that they render.
+func newQueryTemplates(t QueryTemplates) (*queryTemplates, error) {
+	templates := &queryTemplates{}
+
+	for _, q := range []struct {
+		name     string
+		text     string
+		fallback string
+		target   **template.Template
+	}{
+		{name: "success rate", text: t.SuccessRate, fallback: defaultSuccessRateTemplate, target: &templates.successRate},
+		{name: "latency", text: t.Latency, fallback: defaultLatencyTemplate, target: &templates.latency},
+		{name: "volume", text: t.Volume, fallback: defaultVolumeTemplate, target: &templates.volume},
+		{name: "edges", text: t.Edges, fallback: defaultVolumeTemplate, target: &templates.edges},
+	} {
+		text := q.text
+		if strings.TrimSpace(text) == "" {
+			text = q.fallback
+		}
+
+		tmpl, err := template.New(q.name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %s", ErrInvalidTemplate, q.name, err.Error())
+		}
+
+		r := renderer{client: &Client{Labels: " "}, window: rangeWindow{window: "120s"}}
+		data := r.data(inbound, nodeLabels, relabelSource)
+		data.Quantile = strconv.FormatFloat(graph.DefaultQuantile, 'f', -1, 64)
+
+		r.execute(tmpl, data)
+
+		if r.err != nil {
+			return nil, fmt.Errorf("%w %s: %s", ErrInvalidTemplate, q.name, r.err.Error())
+		}
+
+		*q.target = tmpl
+	}
+
+	return templates, nil
+}
+
+// renderer renders the queries of a build, keeping the first error met.
+type renderer struct {
+	client    *Client
+	window    rangeWindow
+	quantiles []float64
+	err       error
+}
+
+func (prometheus Client) renderer(window rangeWindow, quantiles []float64) *renderer {
+	return &renderer{client: &prometheus, window: window, quantiles: quantiles}
+}
+
+func (prometheus Client) templates() *queryTemplates {
+	if prometheus.queryTemplates == nil {
+		return defaultQueryTemplates
+	}
+
+	return prometheus.queryTemplates
+}
+
+// data returns the data of the templates rendering the stats seen from
+// direction, grouped by labels once relabelled by relabelFunc.
+func (r *renderer) data(direction string, labels []model.LabelName, relabelFunc func(string) string) QueryData {
+	formats := rangeFormats[direction]
+	series := func(rangeFormat string) string {
+		return relabelFunc(fmt.Sprintf(rangeFormat, r.client.Labels, r.window.window))
+	}
+
+	return QueryData{
+		Labels:    r.client.Labels,
+		Window:    r.window.window,
+		Grouping:  joinLabels(labels),
+		Direction: direction,
+		Success:   series(formats.success),
+		Responses: series(formats.responses),
+		Requests:  series(formats.requests),
+		Buckets:   series(formats.buckets),
+		relabel:   relabelFunc,
+	}
+}
+
+// render renders tmpl with the data of direction, labels and relabelFunc.
+func (r *renderer) render(
+	tmpl *template.Template, direction string, labels []model.LabelName, relabelFunc func(string) string,
+) string {
+	return r.execute(tmpl, r.data(direction, labels, relabelFunc))
+}
+
+// renderLatency renders the latency template at every quantile, the default
+// quantile when none, in a single query where samples are told apart by
+// their quantile label.
+func (r *renderer) renderLatency(direction string, labels []model.LabelName, relabelFunc func(string) string) string {
+	quantiles := r.quantiles
+	if len(quantiles) == 0 {
+		quantiles = []float64{graph.DefaultQuantile}
+	}
+
+	data := r.data(direction, labels, relabelFunc)
+	queries := make([]string, 0, len(quantiles))
+
+	for _, quantile := range quantiles {
+		data.Quantile = strconv.FormatFloat(quantile, 'f', -1, 64)
+		queries = append(queries, fmt.Sprintf(queryFormatQuantile, r.execute(r.client.templates().latency, data), data.Quantile))
+	}
+
+	return strings.Join(queries, " or ")
+}
+
+func (r *renderer) execute(tmpl *template.Template, data QueryData) string {
+	if r.err != nil {
+		return ""
+	}
+
+	var query strings.Builder
+
+	if err := tmpl.Execute(&query, data); err != nil {
+		r.err = fmt.Errorf("error rendering %s query: %w", tmpl.Name(), err)
+
+		return ""
+	}
+
+	if strings.TrimSpace(query.String()) == "" {
+		r.err = fmt.Errorf("%w %s: empty query", ErrInvalidTemplate, tmpl.Name())
+	}
+
+	return query.String()
+}
@@ -5,33 +5,142 @@ import (
 	"errors"
 	"fmt"
 	"linkerd-nodegraph/internal/graph"
-	"log"
+	"strings"
 	"time"
 
 	prom "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 )
 
-var ErrNotAMatrix = errors.New("expected matrix")
+var (
+	ErrNotAMatrix = errors.New("expected matrix")
+	ErrNotAVector = errors.New("expected vector")
+)
+
+const (
+	// defaultRateWindow is the window of instant queries when the time range
+	// does not give one.
+	defaultRateWindow = 120 * time.Second
+	// DefaultScrapeInterval is the scrape interval of the Prometheus shipped
+	// with Linkerd Viz.
+	DefaultScrapeInterval = 10 * time.Second
+	// minRangeStep is the finest resolution of automatic range query steps.
+	minRangeStep = 30 * time.Second
+	// DefaultMaxPoints bounds the number of points per series of range
+	// queries, so that the step grows with the time range.
+	DefaultMaxPoints = 120
+)
+
+// rangeWindow describes how range formats compute rates.
+type rangeWindow struct {
+	window string
+	// step is the resolution of range queries.
+	step time.Duration
+}
+
+// rangeStep returns the resolution of range queries between from and to, in
+// milliseconds since epoch: override when not zero, or the finest step
+// keeping the number of points per series under the maximum. Overrides finer
+// than the maximum allows are raised to it.
+func (prometheus Client) rangeStep(from int64, to int64, override time.Duration) time.Duration {
+	maxPoints := prometheus.MaxPoints
+	if maxPoints <= 0 {
+		maxPoints = DefaultMaxPoints
+	}
 
-func (prometheus Client) queryRange(ctx context.Context, q string, from int64, to int64) (model.Vector, error) {
+	step := time.Duration(to-from) * time.Millisecond / time.Duration(maxPoints)
+
+	if override > 0 {
+		if override < step.Truncate(time.Second) {
+			return step.Truncate(time.Second)
+		}
+
+		return override
+	}
+
+	if step < minRangeStep {
+		return minRangeStep
+	}
+
+	return step.Truncate(time.Second)
+}
+
+// window returns how to compute the rates between from and to, in
+// milliseconds since epoch, over rateWindow when not zero and with range
+// queries resolved by step when not zero. Range queries evaluate rates at
+// every step over the larger of a step plus a scrape interval and four scrape
+// intervals, as Grafana's $__rate_interval does, so that every window holds
+// enough samples whatever the zoom level. Instant queries evaluate rates over
+// the whole time range at once.
+func (prometheus Client) window(from int64, to int64, rateWindow time.Duration, step time.Duration) rangeWindow {
+	window := rateWindow
+	step = prometheus.rangeStep(from, to, step)
+
+	switch {
+	case window > 0:
+	case prometheus.Instant:
+		window = time.Duration(to-from) * time.Millisecond
+		if window < time.Second {
+			window = defaultRateWindow
+		}
+	default:
+		scrapeInterval := prometheus.ScrapeInterval
+		if scrapeInterval <= 0 {
+			scrapeInterval = DefaultScrapeInterval
+		}
+
+		window = step + scrapeInterval
+		if window < 4*scrapeInterval {
+			window = 4 * scrapeInterval
+		}
+	}
+
+	return rangeWindow{window: fmt.Sprintf("%ds", int64(window.Seconds())), step: step}
+}
+
+// queryVector returns the vector of q between from and to, in milliseconds
+// since epoch, with an instant query at to or with the average of a range
+// query resolved by step, along with the warnings of Prometheus.
+func (prometheus Client) queryVector(
+	ctx context.Context, q string, from int64, to int64, step time.Duration,
+) (model.Vector, prom.Warnings, error) {
+	if prometheus.Instant {
+		return prometheus.queryInstant(ctx, q, to)
+	}
+
+	return prometheus.queryRange(ctx, q, from, to, step)
+}
+
+func (prometheus Client) queryInstant(ctx context.Context, q string, at int64) (model.Vector, prom.Warnings, error) {
+	res, warn, err := prometheus.API.Query(ctx, q, time.Unix(at/1000, 0))
+	if err != nil {
+		return nil, warn, fmt.Errorf("query failed: %q: %w", q, err)
+	}
+
+	vector, ok := res.(model.Vector)
+	if !ok {
+		return nil, warn, fmt.Errorf("received '%s': %w", res.Type(), ErrNotAVector)
+	}
+
+	return vector, warn, nil
+}
+
+func (prometheus Client) queryRange(
+	ctx context.Context, q string, from int64, to int64, step time.Duration,
+) (model.Vector, prom.Warnings, error) {
 	timeRange := prom.Range{
 		Start: time.Unix(from/1000, 0),
 		End:   time.Unix(to/1000, 0),
-		Step:  time.Second * 30,
+		Step:  step,
 	}
 
 	res, warn, err := prometheus.API.QueryRange(ctx, q, timeRange)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %q: %w", q, err)
-	}
-
-	if warn != nil {
-		log.Printf("%v", warn)
+		return nil, warn, fmt.Errorf("query failed: %q: %w", q, err)
 	}
 
 	if _, ok := res.(model.Matrix); !ok {
-		return nil, fmt.Errorf("received '%s': %w", res.Type(), ErrNotAMatrix)
+		return nil, warn, fmt.Errorf("received '%s': %w", res.Type(), ErrNotAMatrix)
 	}
 
 	vector := model.Vector{}
@@ -54,15 +163,35 @@ func (prometheus Client) queryRange(ctx context.Context, q string, from int64, t
 		vector = append(vector, &sample)
 	}
 
-	return vector, nil
+	return vector, warn, nil
+}
+
+// resourceKindToLabel returns the label naming the workloads of kind k,
+// prefixed by prefix.
+func resourceKindToLabel(k graph.ResourceKind, prefix string) model.LabelName {
+	if k == graph.JobKind && prefix == dstPrefix {
+		return dstJobLabel
+	}
+
+	return model.LabelName(prefix) + kindLabelName(k)
 }
 
-func resourceKindToLabel(k graph.ResourceKind) model.LabelName {
+func kindLabelName(k graph.ResourceKind) model.LabelName {
 	switch k {
 	case graph.DeploymentKind:
 		return deploymentLabel
 	case graph.StatefulsetKind:
 		return statefulsetLabel
+	case graph.DaemonsetKind:
+		return daemonsetLabel
+	case graph.CronjobKind:
+		return cronjobLabel
+	case graph.JobKind:
+		return jobLabel
+	case graph.ReplicasetKind:
+		return replicasetLabel
+	case graph.PodKind:
+		return podLabel
 	case graph.UndefinedKind:
 		fallthrough
 	default:
@@ -70,8 +199,155 @@ func resourceKindToLabel(k graph.ResourceKind) model.LabelName {
 	}
 }
 
+// relabelWorkload wraps expr in label_replace calls setting the workload kind
+// and name labels, prefixed by prefix, to the most specific owner found in the
+// series labels. Kinds are applied from the least to the most specific, so a
+// pod owned by a deployment ends up labelled with the deployment.
+func relabelWorkload(expr string, prefix string) string {
+	kindLabel := prefix + string(workloadKindLabel)
+	nameLabel := prefix + string(workloadNameLabel)
+
+	for i := len(graph.ResourceKinds) - 1; i >= 0; i-- {
+		source := string(resourceKindToLabel(graph.ResourceKinds[i], prefix))
+
+		expr = relabel(expr, kindLabel, nameLabel, graph.ResourceKinds[i], source)
+	}
+
+	return expr
+}
+
+// relabelSource is relabelWorkload for sources.
+func relabelSource(expr string) string {
+	return relabelWorkload(expr, "")
+}
+
+// relabelDestination is relabelWorkload for destinations, falling back to a
+// synthetic unmeshed resource named after the destination service, or to an
+// external one named after the authority, when the destination workload is
+// unknown. Destination workloads without inbound series are turned unmeshed
+// afterwards by markUnmeshed.
+func relabelDestination(expr string) string {
+	kindLabel := dstPrefix + string(workloadKindLabel)
+	nameLabel := dstPrefix + string(workloadNameLabel)
+
+	expr = relabel(expr, kindLabel, nameLabel, graph.ExternalKind, string(authorityLabel))
+	expr = relabel(expr, kindLabel, nameLabel, graph.UnmeshedKind, string(dstServiceLabel))
+
+	return relabelWorkload(expr, dstPrefix)
+}
+
+// relabelMember sets the destination workload labels, keeping the
+// destination Service untouched.
+func relabelMember(expr string) string {
+	return relabelWorkload(expr, dstPrefix)
+}
+
+// relabelService sets the destination kind and name labels to the destination
+// Service, or to an external resource named after the authority when the
+// destination has no Service.
+func relabelService(expr string) string {
+	kindLabel := dstPrefix + string(workloadKindLabel)
+	nameLabel := dstPrefix + string(workloadNameLabel)
+
+	expr = relabel(expr, kindLabel, nameLabel, graph.ExternalKind, string(authorityLabel))
+
+	return relabel(expr, kindLabel, nameLabel, graph.ServiceKind, string(dstServiceLabel))
+}
+
+// relabel wraps expr so that series with a non empty source label get kind
+// in kindLabel and the source label value in nameLabel.
+func relabel(expr string, kindLabel string, nameLabel string, kind graph.ResourceKind, source string) string {
+	return fmt.Sprintf(
+		`label_replace(label_replace(%s, "%s", "%s", "%s", ".+"), "%s", "$1", "%s", "(.+)")`,
+		expr, kindLabel, kind.String(), source, nameLabel, source)
+}
+
+// sampleResource returns the resource described by the workload labels of
+// metric, prefixed by prefix.
+func sampleResource(metric model.Metric, prefix string) graph.Resource {
+	return graph.Resource{
+		Namespace: string(metric[model.LabelName(prefix)+namespaceLabel]),
+		Name:      string(metric[model.LabelName(prefix)+workloadNameLabel]),
+		Kind:      graph.ResourceKindFromString(string(metric[model.LabelName(prefix)+workloadKindLabel])),
+		Cluster:   string(metric[model.LabelName(prefix)+targetClusterLabel]),
+	}
+}
+
+// stitchMirrors rewrites the destination of the samples of vectors sent to a
+// service mirrored from another cluster, known by the name of the mirror
+// only, into the Service it mirrors. Mirrors are named after the mirrored
+// Service followed by the name of their cluster or by mirrorSuffix.
+func stitchMirrors(vectors ...model.Vector) {
+	for _, vector := range vectors {
+		for _, sample := range vector {
+			cluster := string(sample.Metric[dstTargetClusterLabel])
+			kind := graph.ResourceKindFromString(string(sample.Metric[dstWorkloadKindLabel]))
+
+			if cluster == "" || (kind != graph.UnmeshedKind && kind != graph.ServiceKind) {
+				continue
+			}
+
+			name := string(sample.Metric[dstWorkloadNameLabel])
+			name = strings.TrimSuffix(name, "-"+cluster)
+			name = strings.TrimSuffix(name, mirrorSuffix)
+
+			metric := sample.Metric.Clone()
+			metric[dstWorkloadKindLabel] = model.LabelValue(graph.ServiceKind.String())
+			metric[dstWorkloadNameLabel] = model.LabelValue(name)
+			sample.Metric = metric
+		}
+	}
+}
+
+// markUnmeshed rewrites the destination of the samples of vectors sent to a
+// workload of this cluster without inbound series, such as a pod without
+// proxy, into an unmeshed resource named after the workload, whatever its
+// workload labels. Pods owned by no workload are named after the pod. Both
+// sides are relabelled by relabelWorkload, so that a meshed workload has the
+// same key in inbound and in vectors.
+func markUnmeshed(inbound model.Vector, vectors ...model.Vector) {
+	meshed := make(map[resourceKey]bool, len(inbound))
+	for _, sample := range inbound {
+		meshed[metricResourceKey(sample.Metric, "")] = true
+	}
+
+	for _, vector := range vectors {
+		for _, sample := range vector {
+			kind := graph.ResourceKindFromString(string(sample.Metric[dstWorkloadKindLabel]))
+
+			if kind == graph.UndefinedKind || kind == graph.ServiceKind || kind.Synthetic() ||
+				sample.Metric[dstTargetClusterLabel] != "" || meshed[metricResourceKey(sample.Metric, dstPrefix)] {
+				continue
+			}
+
+			metric := sample.Metric.Clone()
+			metric[dstWorkloadKindLabel] = model.LabelValue(graph.UnmeshedKind.String())
+			sample.Metric = metric
+		}
+	}
+}
+
+// kindValue returns the workload kind label value of k. Undefined kinds are
+// looked up as deployments.
+func kindValue(k graph.ResourceKind) model.LabelValue {
+	if k == graph.UndefinedKind {
+		return model.LabelValue(graph.DeploymentKind.String())
+	}
+
+	return model.LabelValue(k.String())
+}
+
+func joinLabels(labels []model.LabelName) string {
+	names := make([]string, 0, len(labels))
+	for _, label := range labels {
+		names = append(names, string(label))
+	}
+
+	return strings.Join(names, ", ")
+}
+
 func validEdgeSample(sample model.Sample) bool {
-	if _, ok := sample.Metric[dstNamespaceLabel]; !ok {
+	if _, ok := sample.Metric[dstWorkloadKindLabel]; !ok {
 		return false
 	}
 
@@ -81,3 +357,23 @@ func validEdgeSample(sample model.Sample) bool {
 
 	return true
 }
+
+// sampleOverhead approximates the memory used by a sample besides its labels.
+const sampleOverhead = 64
+
+// vectorsSize estimates the memory used by vectors, in bytes.
+func vectorsSize(vectors ...model.Vector) int {
+	size := 0
+
+	for _, vector := range vectors {
+		for _, sample := range vector {
+			size += sampleOverhead
+
+			for name, value := range sample.Metric {
+				size += len(name) + len(value)
+			}
+		}
+	}
+
+	return size
+}
@@ -0,0 +1,112 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"linkerd-nodegraph/internal/config"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source/file"
+	"linkerd-nodegraph/internal/graph/source/multicluster"
+	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"linkerd-nodegraph/internal/graph/source/viz"
+	"sync"
+)
+
+var ErrUnknownSource = errors.New("unknown graph source")
+
+// Factory creates the graph.Source described by a configuration.
+type Factory func(cnf *config.Config) (graph.Source, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[config.GraphSource]Factory{
+		config.PrometheusGraphSource: newPrometheus,
+		config.VizGraphSource:        newViz,
+		config.FileGraphSource:       newFile,
+	}
+)
+
+// Register makes factory available under name, replacing any previous one
+// until the returned function is called.
+func Register(name config.GraphSource, factory Factory) (unregister func()) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	previous, replaced := factories[name]
+	factories[name] = factory
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if replaced {
+			factories[name] = previous
+		} else {
+			delete(factories, name)
+		}
+	}
+}
+
+// New returns the graph.Source selected by cnf.GraphSource.
+func New(cnf *config.Config) (graph.Source, error) {
+	mu.RLock()
+	factory, ok := factories[cnf.GraphSource]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSource, cnf.GraphSource)
+	}
+
+	return factory(cnf)
+}
+
+func newPrometheus(cnf *config.Config) (graph.Source, error) {
+	if len(cnf.Prometheus.Clusters) > 0 {
+		return newMulticlusterPrometheus(cnf)
+	}
+
+	promConfig, err := cnf.Prometheus.Config()
+	if err != nil {
+		return nil, fmt.Errorf("invalid prometheus configuration: %w", err)
+	}
+
+	client, err := prometheus.NewClient(*promConfig)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return client, nil
+}
+
+func newMulticlusterPrometheus(cnf *config.Config) (graph.Source, error) {
+	clusters := []multicluster.Cluster{}
+
+	for _, cluster := range cnf.Prometheus.Clusters {
+		promConfig, err := cnf.Prometheus.ClusterConfig(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prometheus configuration of cluster %s: %w", cluster.Name, err)
+		}
+
+		client, err := prometheus.NewClient(*promConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.Name, err)
+		}
+
+		clusters = append(clusters, multicluster.Cluster{Name: cluster.Name, Source: client})
+	}
+
+	return multicluster.NewSource(clusters...), nil
+}
+
+func newViz(cnf *config.Config) (graph.Source, error) {
+	vizConfig, err := cnf.Viz.Config()
+	if err != nil {
+		return nil, fmt.Errorf("invalid linkerd viz configuration: %w", err)
+	}
+
+	return viz.NewClient(*vizConfig), nil
+}
+
+func newFile(cnf *config.Config) (graph.Source, error) {
+	return file.NewSource(cnf.File.Path), nil
+}
@@ -0,0 +1,36 @@
+package source_test
+
+import (
+	"errors"
+	"linkerd-nodegraph/internal/config"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	cnf := config.Default()
+
+	s, err := source.New(cnf)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+
+	cnf.GraphSource = "foo"
+
+	_, err = source.New(cnf)
+	assert.True(t, errors.Is(err, source.ErrUnknownSource))
+
+	unregister := source.Register("foo", func(cnf *config.Config) (graph.Source, error) {
+		return nil, nil
+	})
+
+	_, err = source.New(cnf)
+	assert.Nil(t, err)
+
+	unregister()
+
+	_, err = source.New(cnf)
+	assert.True(t, errors.Is(err, source.ErrUnknownSource))
+}
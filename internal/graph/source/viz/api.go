@@ -0,0 +1,169 @@
+package viz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	statSummaryPath = "/api/tps-reports"
+	edgesPath       = "/api/edges"
+)
+
+var ErrAPI = errors.New("linkerd viz api error")
+
+// resource is a Kubernetes resource as described by the Viz API.
+type resource struct {
+	Namespace string `json:"namespace"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+}
+
+// count is a protobuf uint64, which the Viz API renders as a JSON string.
+type count uint64
+
+func (c *count) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 {
+		*c = 0
+
+		return nil
+	}
+
+	value, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid count %q: %w", data, err)
+	}
+
+	*c = count(value)
+
+	return nil
+}
+
+type basicStats struct {
+	SuccessCount count `json:"successCount"`
+	FailureCount count `json:"failureCount"`
+	LatencyMsP50 count `json:"latencyMsP50"`
+	LatencyMsP95 count `json:"latencyMsP95"`
+	LatencyMsP99 count `json:"latencyMsP99"`
+}
+
+type statRow struct {
+	Resource resource    `json:"resource"`
+	Stats    *basicStats `json:"stats"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type statSummaryResponse struct {
+	Ok *struct {
+		StatTables []struct {
+			PodGroup struct {
+				Rows []statRow `json:"rows"`
+			} `json:"podGroup"`
+		} `json:"statTables"`
+	} `json:"ok"`
+	Error *apiError `json:"error"`
+}
+
+type edge struct {
+	Src resource `json:"src"`
+	Dst resource `json:"dst"`
+}
+
+type edgesResponse struct {
+	Ok *struct {
+		Edges []edge `json:"edges"`
+	} `json:"ok"`
+	Error *apiError `json:"error"`
+}
+
+// statSummary returns the rows of the StatSummary API called with params.
+func (c *Client) statSummary(ctx context.Context, params url.Values) ([]statRow, error) {
+	var response statSummaryResponse
+
+	if err := c.get(ctx, statSummaryPath, params, &response); err != nil {
+		return nil, err
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAPI, response.Error.Error)
+	}
+
+	rows := []statRow{}
+
+	if response.Ok == nil {
+		return rows, nil
+	}
+
+	for _, table := range response.Ok.StatTables {
+		rows = append(rows, table.PodGroup.Rows...)
+	}
+
+	return rows, nil
+}
+
+// edges returns the edges of the resources of type resourceType in namespace.
+func (c *Client) edges(ctx context.Context, namespace string, resourceType string) ([]edge, error) {
+	var response edgesResponse
+
+	params := url.Values{
+		"namespace":     {namespace},
+		"resource_type": {resourceType},
+	}
+
+	if err := c.get(ctx, edgesPath, params, &response); err != nil {
+		return nil, err
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAPI, response.Error.Error)
+	}
+
+	if response.Ok == nil {
+		return []edge{}, nil
+	}
+
+	return response.Ok.Edges, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, params url.Values, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.address+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error querying %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading %s response: %w", path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %s: %s", ErrAPI, path, resp.Status, bytes.TrimSpace(body))
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("error decoding %s response: %w", path, err)
+	}
+
+	return nil
+}
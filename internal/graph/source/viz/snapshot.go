@@ -0,0 +1,113 @@
+package viz
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+)
+
+// edgeStats holds the stats of the traffic from source to destination.
+type edgeStats struct {
+	source           graph.Resource
+	destination      graph.Resource
+	requestRate      float64
+	successRate      float64
+	successRateKnown bool
+	latencies        graph.Latencies
+}
+
+// Snapshot is a graph.Snapshot built from the Viz API.
+type Snapshot struct {
+	nodes     map[graph.Resource]graph.Node
+	resources []graph.Resource
+	edges     []*edgeStats
+}
+
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		nodes:     map[graph.Resource]graph.Node{},
+		resources: []graph.Resource{},
+		edges:     []*edgeStats{},
+	}
+}
+
+func (s *Snapshot) addNode(node graph.Node) {
+	if _, ok := s.nodes[node.Resource]; !ok {
+		s.resources = append(s.resources, node.Resource)
+	}
+
+	s.nodes[node.Resource] = node
+}
+
+// Node returns the graph.Node associated with resource.
+func (s *Snapshot) Node(ctx context.Context, resource graph.Resource) *graph.Node {
+	node, ok := s.nodes[resource]
+	if !ok {
+		return &graph.Node{Resource: resource}
+	}
+
+	return &node
+}
+
+// Resources returns every resource seen in the stats and the edges.
+func (s *Snapshot) Resources(ctx context.Context) []graph.Resource {
+	resources := append([]graph.Resource{}, s.resources...)
+	seen := map[graph.Resource]bool{}
+
+	for _, resource := range s.resources {
+		seen[resource] = true
+	}
+
+	for _, e := range s.edges {
+		for _, resource := range []graph.Resource{e.source, e.destination} {
+			if !seen[resource] {
+				seen[resource] = true
+				resources = append(resources, resource)
+			}
+		}
+	}
+
+	return resources
+}
+
+func (s *Snapshot) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, e := range s.edges {
+		if e.source == node.Resource {
+			edges = append(edges, e.edge(node, s.Node(ctx, e.destination)))
+		}
+	}
+
+	return edges
+}
+
+func (s *Snapshot) DownstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, e := range s.edges {
+		if e.destination == node.Resource {
+			edges = append(edges, e.edge(s.Node(ctx, e.source), node))
+		}
+	}
+
+	return edges
+}
+
+func (s *Snapshot) EdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+	edges = append(edges, s.UpstreamEdgesOf(ctx, node)...)
+	edges = append(edges, s.DownstreamEdgesOf(ctx, node)...)
+
+	return edges
+}
+
+func (e *edgeStats) edge(source *graph.Node, destination *graph.Node) graph.Edge {
+	return graph.Edge{
+		Source:           source,
+		Destination:      destination,
+		RequestRate:      e.requestRate,
+		SuccessRate:      e.successRate,
+		SuccessRateKnown: e.successRateKnown,
+		Latencies:        e.latencies,
+	}
+}
@@ -0,0 +1,351 @@
+package viz
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultWindow is used when the query has no time range, in seconds.
+	defaultWindow = 60
+	// maxConcurrentRequests bounds the requests in flight to the Viz API.
+	maxConcurrentRequests = 8
+)
+
+// resourceKinds are the workload kinds queried from the Viz API.
+var resourceKinds = []graph.ResourceKind{
+	graph.DeploymentKind,
+	graph.StatefulsetKind,
+	graph.DaemonsetKind,
+	graph.CronjobKind,
+	graph.JobKind,
+}
+
+type Config struct {
+	// Address is the base URL of the Linkerd Viz web API.
+	Address   string
+	Headers   map[string]string
+	TLSConfig *tls.Config
+}
+
+// Client builds snapshots out of the StatSummary and Edges APIs of Linkerd
+// Viz.
+type Client struct {
+	client  *http.Client
+	address string
+	headers map[string]string
+}
+
+func NewClient(config Config) *Client {
+	return &Client{
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: config.TLSConfig,
+			},
+		},
+		address: strings.TrimSuffix(config.Address, "/"),
+		headers: config.Headers,
+	}
+}
+
+// Snapshot builds the snapshot described by query. The Viz API only reports
+// stats over a window ending now, so the window lasts as long as the time
+// range of the query, or the rate window when set, but ignores where it ends.
+func (c *Client) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	if query.View != graph.WorkloadView {
+		return nil, fmt.Errorf("%w: %q", graph.ErrUnsupportedView, query.View)
+	}
+
+	seconds := (query.To - query.From) / 1000
+	if query.RateWindow > 0 {
+		seconds = int64(query.RateWindow.Seconds())
+	}
+
+	if seconds <= 0 {
+		seconds = defaultWindow
+	}
+
+	snapshot, err := c.build(ctx, seconds)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (c *Client) build(ctx context.Context, seconds int64) (*Snapshot, error) {
+	window := fmt.Sprintf("%ds", seconds)
+	snapshot := newSnapshot()
+
+	rows, err := c.workloadStats(ctx, window)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		resource, ok := row.Resource.graphResource()
+		if !ok {
+			continue
+		}
+
+		snapshot.addNode(row.Stats.node(resource, seconds))
+	}
+
+	edges, err := c.workloadEdges(ctx, snapshot.resources)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.edgeStats(ctx, window, seconds, edges); err != nil {
+		return nil, err
+	}
+
+	snapshot.edges = edges
+
+	return snapshot, nil
+}
+
+// workloadStats returns the stats of every workload of resourceKinds.
+func (c *Client) workloadStats(ctx context.Context, window string) ([]statRow, error) {
+	results := make([][]statRow, len(resourceKinds))
+	requests := make([]func() error, len(resourceKinds))
+
+	for i, kind := range resourceKinds {
+		i, kind := i, kind
+		requests[i] = func() error {
+			rows, err := c.statSummary(ctx, url.Values{
+				"resource_type":  {kind.String()},
+				"all_namespaces": {"true"},
+				"window":         {window},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to get %s stats: %w", kind, err)
+			}
+
+			results[i] = rows
+
+			return nil
+		}
+	}
+
+	if err := runAll(requests); err != nil {
+		return nil, err
+	}
+
+	rows := []statRow{}
+	for _, result := range results {
+		rows = append(rows, result...)
+	}
+
+	return rows, nil
+}
+
+// workloadEdges returns the edges of every namespace and kind of resources,
+// once each.
+func (c *Client) workloadEdges(ctx context.Context, resources []graph.Resource) ([]*edgeStats, error) {
+	type scope struct {
+		namespace string
+		kind      graph.ResourceKind
+	}
+
+	scopes := []scope{}
+	seenScopes := map[scope]bool{}
+
+	for _, resource := range resources {
+		s := scope{namespace: resource.Namespace, kind: resource.Kind}
+		if !seenScopes[s] {
+			seenScopes[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+
+	results := make([][]edge, len(scopes))
+	requests := make([]func() error, len(scopes))
+
+	for i, s := range scopes {
+		i, s := i, s
+		requests[i] = func() error {
+			edges, err := c.edges(ctx, s.namespace, s.kind.String())
+			if err != nil {
+				return fmt.Errorf("failed to get %s edges in %s: %w", s.kind, s.namespace, err)
+			}
+
+			results[i] = edges
+
+			return nil
+		}
+	}
+
+	if err := runAll(requests); err != nil {
+		return nil, err
+	}
+
+	edges := []*edgeStats{}
+	seen := map[[2]graph.Resource]bool{}
+
+	for _, result := range results {
+		for _, e := range result {
+			source, ok := e.Src.graphResource()
+			if !ok {
+				continue
+			}
+
+			destination, ok := e.Dst.graphResource()
+			if !ok {
+				continue
+			}
+
+			key := [2]graph.Resource{source, destination}
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+			edges = append(edges, &edgeStats{source: source, destination: destination})
+		}
+	}
+
+	return edges, nil
+}
+
+// edgeStats sets the stats of edges from the outbound stats of their sources,
+// querying once per kind of source and destination.
+func (c *Client) edgeStats(ctx context.Context, window string, seconds int64, edges []*edgeStats) error {
+	type group struct {
+		kind        graph.ResourceKind
+		destination graph.Resource
+	}
+
+	groups := []group{}
+	members := map[group][]*edgeStats{}
+
+	for _, e := range edges {
+		g := group{kind: e.source.Kind, destination: e.destination}
+		if _, ok := members[g]; !ok {
+			groups = append(groups, g)
+		}
+
+		members[g] = append(members[g], e)
+	}
+
+	results := make([][]statRow, len(groups))
+	requests := make([]func() error, len(groups))
+
+	for i, g := range groups {
+		i, g := i, g
+		requests[i] = func() error {
+			rows, err := c.statSummary(ctx, url.Values{
+				"resource_type":  {g.kind.String()},
+				"all_namespaces": {"true"},
+				"to_type":        {g.destination.Kind.String()},
+				"to_name":        {g.destination.Name},
+				"to_namespace":   {g.destination.Namespace},
+				"window":         {window},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to get stats of edges to %s: %w", g.destination.Name, err)
+			}
+
+			results[i] = rows
+
+			return nil
+		}
+	}
+
+	if err := runAll(requests); err != nil {
+		return err
+	}
+
+	for i, g := range groups {
+		for _, row := range results[i] {
+			source, ok := row.Resource.graphResource()
+			if !ok {
+				continue
+			}
+
+			for _, e := range members[g] {
+				if e.source == source {
+					node := row.Stats.node(source, seconds)
+					e.requestRate = node.RequestVolume
+					e.successRate = node.SuccessRate
+					e.successRateKnown = node.SuccessRateKnown
+					e.latencies = node.Latencies
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// runAll runs requests concurrently, at most maxConcurrentRequests at once,
+// and returns the first error met.
+func runAll(requests []func() error) error {
+	errs := make([]error, len(requests))
+	semaphore := make(chan struct{}, maxConcurrentRequests)
+
+	var wg sync.WaitGroup
+
+	for i, request := range requests {
+		wg.Add(1)
+
+		go func(i int, request func() error) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			errs[i] = request()
+		}(i, request)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r resource) graphResource() (graph.Resource, bool) {
+	kind := graph.ResourceKindFromString(r.Type)
+	if kind == graph.UndefinedKind || r.Name == "" {
+		return graph.Resource{}, false
+	}
+
+	return graph.Resource{Name: r.Name, Namespace: r.Namespace, Kind: kind}, true
+}
+
+// node returns the graph.Node of resource with stats s, counted over seconds.
+func (s *basicStats) node(resource graph.Resource, seconds int64) graph.Node {
+	node := graph.Node{Resource: resource}
+
+	if s == nil {
+		return node
+	}
+
+	requests := float64(s.SuccessCount + s.FailureCount)
+	if requests > 0 {
+		node.SuccessRate = float64(s.SuccessCount) / requests
+		node.SuccessRateKnown = true
+		node.RequestVolume = requests / float64(seconds)
+	}
+
+	// The Viz API computes a fixed set of quantiles, others are unknown.
+	node.Latencies = graph.Latencies{
+		0.5:  float64(s.LatencyMsP50),
+		0.95: float64(s.LatencyMsP95),
+		0.99: float64(s.LatencyMsP99),
+	}
+
+	return node
+}
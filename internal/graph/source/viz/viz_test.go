@@ -0,0 +1,127 @@
+package viz_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/graph/source/viz"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const edgesResponse = `{"ok":{"edges":[{
+	"src":{"namespace":"front","type":"deployment","name":"web"},
+	"dst":{"namespace":"back","type":"deployment","name":"api"},
+	"clientId":"web.front.serviceaccount.identity.linkerd.cluster.local",
+	"serverId":"api.back.serviceaccount.identity.linkerd.cluster.local"
+}]}}`
+
+func statRow(namespace string, name string, success int, failure int, latency int) string {
+	return fmt.Sprintf(`{
+		"resource":{"namespace":%q,"type":"deployment","name":%q},
+		"timeWindow":"60s",
+		"meshedPodCount":"1",
+		"stats":{"successCount":"%d","failureCount":"%d","latencyMsP50":"1","latencyMsP95":"%d","latencyMsP99":"50"}
+	}`, namespace, name, success, failure, latency)
+}
+
+func statResponse(rows ...string) string {
+	response := `{"ok":{"statTables":[{"podGroup":{"rows":[`
+
+	for i, row := range rows {
+		if i > 0 {
+			response += ","
+		}
+
+		response += row
+	}
+
+	return response + `]}}]}}`
+}
+
+func stubServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/tps-reports", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "60s", query.Get("window"))
+
+		switch {
+		case query.Get("resource_type") != "deployment":
+			fmt.Fprint(w, statResponse())
+		case query.Get("to_name") == "api":
+			assert.Equal(t, "back", query.Get("to_namespace"))
+			assert.Equal(t, "deployment", query.Get("to_type"))
+			fmt.Fprint(w, statResponse(statRow("front", "web", 54, 6, 30)))
+		default:
+			fmt.Fprint(w, statResponse(
+				statRow("front", "web", 120, 0, 10),
+				statRow("back", "api", 90, 30, 20),
+			))
+		}
+	})
+
+	mux.HandleFunc("/api/edges", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, edgesResponse)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestClientSnapshot(t *testing.T) {
+	server := stubServer(t)
+	defer server.Close()
+
+	client := viz.NewClient(viz.Config{Address: server.URL})
+
+	snapshot, err := client.Snapshot(context.Background(), graph.Query{
+		From: 0,
+		To:   60000,
+		View: graph.WorkloadView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := graph.Resource{Name: "web", Namespace: "front", Kind: graph.DeploymentKind}
+	api := graph.Resource{Name: "api", Namespace: "back", Kind: graph.DeploymentKind}
+
+	assert.ElementsMatch(t, []graph.Resource{web, api}, snapshot.Resources(context.Background()))
+
+	apiNode := snapshot.Node(context.Background(), api)
+	assert.Equal(t, &graph.Node{
+		Resource: api, SuccessRate: 0.75, SuccessRateKnown: true, Latencies: graph.Latencies{0.5: 1, 0.95: 20, 0.99: 50}, RequestVolume: 2,
+	}, apiNode)
+
+	webNode := snapshot.Node(context.Background(), web)
+	edges := snapshot.UpstreamEdgesOf(context.Background(), webNode)
+	assert.Len(t, edges, 1)
+	assert.Equal(t, api, edges[0].Destination.Resource)
+	assert.Equal(t, 1.0, edges[0].RequestRate)
+	assert.Equal(t, 0.9, edges[0].SuccessRate)
+	assert.Equal(t, 30.0, edges[0].Latencies[0.95])
+
+	assert.Len(t, snapshot.DownstreamEdgesOf(context.Background(), apiNode), 1)
+	assert.Len(t, snapshot.DownstreamEdgesOf(context.Background(), webNode), 0)
+}
+
+func TestClientSnapshotErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error":{"error":"invalid resource type"}}`)
+	}))
+	defer server.Close()
+
+	client := viz.NewClient(viz.Config{Address: server.URL})
+
+	_, err := client.Snapshot(context.Background(), graph.Query{View: graph.WorkloadView})
+	assert.True(t, errors.Is(err, viz.ErrAPI))
+
+	_, err = client.Snapshot(context.Background(), graph.Query{View: graph.ServiceView})
+	assert.True(t, errors.Is(err, graph.ErrUnsupportedView))
+}
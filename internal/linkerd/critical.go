@@ -0,0 +1,100 @@
+package linkerd
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/nodegraph"
+)
+
+// criticalPathColor is the color of the edges of the critical path.
+const criticalPathColor = "purple"
+
+// criticalPath walks from root along the outbound edges of the graph,
+// following at each hop the edge contributing the most to latency: the
+// latency at quantile seen over the edge, or of its destination when unknown,
+// weighted by the share of the caller's outbound requests sent over it. It stops at
+// leaves, after maxDepth hops or when coming back to a node already walked.
+func criticalPath(
+	ctx context.Context, b graph.Snapshot, root *graph.Node, maxDepth int, inGraph map[string]bool, quantile float64,
+) []graph.Edge {
+	path := []graph.Edge{}
+	visited := map[string]bool{root.ID(): true}
+	node := root
+
+	for depth := 0; depth < maxDepth; depth++ {
+		edges := []graph.Edge{}
+		total := 0.0
+
+		for _, edge := range b.UpstreamEdgesOf(ctx, node) {
+			if !inGraph[edge.ID()] {
+				continue
+			}
+
+			edges = append(edges, edge)
+			total += edge.RequestRate
+		}
+
+		var critical *graph.Edge
+
+		best := 0.0
+
+		for i := range edges {
+			contribution := latencyContribution(edges[i], total, quantile)
+			if critical == nil || contribution > best {
+				critical = &edges[i]
+				best = contribution
+			}
+		}
+
+		if critical == nil || visited[critical.Destination.ID()] {
+			break
+		}
+
+		path = append(path, *critical)
+		visited[critical.Destination.ID()] = true
+		node = critical.Destination
+	}
+
+	return path
+}
+
+func latencyContribution(edge graph.Edge, total float64, quantile float64) float64 {
+	latency := edge.Latencies[quantile]
+	if latency == 0 {
+		latency = edge.Destination.Latencies[quantile]
+	}
+
+	if total == 0 {
+		return latency
+	}
+
+	return latency * edge.RequestRate / total
+}
+
+// highlight marks the nodes and edges of path in nodeGraph, coloring the
+// edges and filling the critical path arc of the nodes. The success rate arcs
+// of the nodes are left untouched.
+func highlight(nodeGraph *nodegraph.Graph, path []graph.Edge) {
+	nodeIDs := map[string]bool{}
+	edgeIDs := map[string]bool{}
+
+	for _, edge := range path {
+		nodeIDs[edge.Source.ID()] = true
+		nodeIDs[edge.Destination.ID()] = true
+		edgeIDs[edge.ID()] = true
+	}
+
+	for _, node := range nodeGraph.Nodes {
+		if nodeIDs[node["id"].(string)] {
+			node["highlighted"] = true
+			node["arc__critical"] = 1.0
+		}
+	}
+
+	for _, edge := range nodeGraph.Edges {
+		if edgeIDs[edge["id"].(string)] {
+			edge["highlighted"] = true
+			edge["color"] = criticalPathColor
+		}
+	}
+}
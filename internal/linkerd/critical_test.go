@@ -0,0 +1,73 @@
+package linkerd
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/nodegraph"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CriticalPath(t *testing.T) {
+	web := graph.Node{Resource: deployment("shop", "web"), Latencies: graph.Latencies{0.95: 300}}
+	search := graph.Node{Resource: deployment("shop", "search"), Latencies: graph.Latencies{0.95: 500}}
+	checkout := graph.Node{Resource: deployment("shop", "checkout"), Latencies: graph.Latencies{0.95: 200}}
+	db := graph.Node{Resource: deployment("shop", "db"), Latencies: graph.Latencies{0.95: 150}}
+	edges := []graph.Edge{
+		// search is slower but only gets a tenth of the requests.
+		{Source: &web, Destination: &search, RequestRate: 1},
+		{Source: &web, Destination: &checkout, RequestRate: 9},
+		{Source: &checkout, Destination: &db, RequestRate: 5},
+		{Source: &db, Destination: &web, RequestRate: 1},
+	}
+	snapshot := fakeSnapshot{nodes: []graph.Node{web, search, checkout, db}, edges: edges}
+
+	inGraph := map[string]bool{}
+	for _, edge := range edges {
+		inGraph[edge.ID()] = true
+	}
+
+	path := criticalPath(context.Background(), snapshot, &web, 5, inGraph, 0.95)
+	if assert.Len(t, path, 2) {
+		assert.Equal(t, edges[1].ID(), path[0].ID())
+		assert.Equal(t, edges[2].ID(), path[1].ID())
+	}
+
+	path = criticalPath(context.Background(), snapshot, &web, 1, inGraph, 0.95)
+	assert.Len(t, path, 1)
+}
+
+func Test_HighlightKeepsSuccessRate(t *testing.T) {
+	web := graph.Node{Resource: deployment("shop", "web"), SuccessRate: 0.5}
+	api := graph.Node{Resource: deployment("shop", "api"), SuccessRate: 0.75}
+	db := graph.Node{Resource: deployment("shop", "db"), SuccessRate: 1}
+	edges := []graph.Edge{{Source: &web, Destination: &api}, {Source: &web, Destination: &db}}
+	latency := latencySpec{quantiles: []float64{0.95}, selected: 0.95}
+
+	nodeGraph := nodegraph.Graph{Spec: latency.nodeFields()}
+	for _, node := range []graph.Node{web, api, db} {
+		assert.NoError(t, nodeGraph.AddNode(nodegraphNode(node, false, latency)))
+	}
+
+	for _, edge := range edges {
+		assert.NoError(t, nodeGraph.AddEdge(nodegraphEdge(edge, latency)))
+	}
+
+	highlight(&nodeGraph, edges[:1])
+
+	for i, critical := range []float64{1, 1, 0} {
+		assert.Equal(t, critical == 1, nodeGraph.Nodes[i]["highlighted"])
+		assert.Equal(t, critical, nodeGraph.Nodes[i]["arc__critical"])
+	}
+
+	assert.Equal(t, 0.5, nodeGraph.Nodes[0]["arc__success"])
+	assert.Equal(t, 0.5, nodeGraph.Nodes[0]["arc__failed"])
+	assert.Equal(t, 0.75, nodeGraph.Nodes[1]["arc__success"])
+	assert.Equal(t, 0.25, nodeGraph.Nodes[1]["arc__failed"])
+
+	assert.Equal(t, true, nodeGraph.Edges[0]["highlighted"])
+	assert.Equal(t, criticalPathColor, nodeGraph.Edges[0]["color"])
+	assert.Equal(t, false, nodeGraph.Edges[1]["highlighted"])
+	assert.Equal(t, "", nodeGraph.Edges[1]["color"])
+}
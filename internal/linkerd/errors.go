@@ -0,0 +1,47 @@
+package linkerd
+
+import (
+	"errors"
+)
+
+var (
+	// ErrInvalidParameters matches every error caused by the parameters of a
+	// request.
+	ErrInvalidParameters = errors.New("invalid parameters")
+	// ErrSource matches every error of the graph source.
+	ErrSource = errors.New("graph source failed")
+)
+
+// parameterError is an error caused by the parameters of a request.
+type parameterError struct {
+	text string
+}
+
+func newParameterError(text string) error {
+	return &parameterError{text: text}
+}
+
+func (e *parameterError) Error() string {
+	return e.text
+}
+
+func (e *parameterError) Is(target error) bool {
+	return target == ErrInvalidParameters //nolint:errorlint
+}
+
+// sourceError is an error of the graph source, which it wraps.
+type sourceError struct {
+	err error
+}
+
+func (e *sourceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sourceError) Unwrap() error {
+	return e.err
+}
+
+func (e *sourceError) Is(target error) bool {
+	return target == ErrSource //nolint:errorlint
+}
@@ -4,92 +4,133 @@ import (
 	"context"
 	"fmt"
 	"linkerd-nodegraph/internal/graph"
-	"linkerd-nodegraph/internal/graph/source/prometheus"
+	"linkerd-nodegraph/internal/metrics"
 	"linkerd-nodegraph/internal/nodegraph"
+	"strings"
+	"time"
 )
 
 const (
 	defaultUnknownValue = "N/A"
 )
 
+var (
+	ErrInvalidRoot       = newParameterError("root must be cluster/namespace/kind/name, namespace/kind/name or namespace/name")
+	ErrInvalidTarget     = newParameterError("target must be cluster/namespace/kind/name, namespace/kind/name or namespace/name")
+	ErrInvalidRateWindow = newParameterError("rate window must be a positive duration such as 5m")
+	ErrInvalidStep       = newParameterError("step must be a positive duration such as 1m")
+)
+
 type Stats struct {
-	Server *prometheus.Client
+	Source graph.Source
+	// Quantiles are the latency quantiles shown when the request does not
+	// choose any, DefaultQuantile when empty.
+	Quantiles []float64
+	// MaxDepth bounds the depth of requests, unbounded when zero.
+	MaxDepth int
+	// MaxTimeRange bounds the time range of requests, unbounded when zero.
+	MaxTimeRange time.Duration
+
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
 }
 
 type Parameters struct {
-	Depth     int    `schema:"depth"`
-	Name      string `schema:"name"`
-	Namespace string `schema:"namespace"`
-	Kind      string `schema:"kind"`
-	Direction string `schema:"direction"`
-	From      int64  `schema:"from"`
-	To        int64  `schema:"to"`
-}
-
-var GraphSpec = nodegraph.NodeFields{
-	Edge: []nodegraph.Field{
-		{Name: "id", Type: nodegraph.FieldTypeString},
-		{Name: "source", Type: nodegraph.FieldTypeString},
-		{Name: "target", Type: nodegraph.FieldTypeString},
-	},
-	Node: []nodegraph.Field{
-		{Name: "id", Type: nodegraph.FieldTypeString},
-		{Name: "title", Type: nodegraph.FieldTypeString, DisplayName: "Resource"},
-		{Name: "mainStat", Type: nodegraph.FieldTypeString, DisplayName: "Success Rate"},
-		{Name: "secondaryStat", Type: nodegraph.FieldTypeString, DisplayName: "Latency"},
-		{Name: "detail__type", Type: nodegraph.FieldTypeString, DisplayName: "Type"},
-		{Name: "detail__namespace", Type: nodegraph.FieldTypeString, DisplayName: "Namespace"},
-		{Name: "detail__name", Type: nodegraph.FieldTypeString, DisplayName: "Name"},
-		{Name: "detail__successRate", Type: nodegraph.FieldTypeString, DisplayName: "Success Rate"},
-		{Name: "detail__latency_p95", Type: nodegraph.FieldTypeString, DisplayName: "p95"},
-		{Name: "detail__volume", Type: nodegraph.FieldTypeString, DisplayName: "Request volume"},
-		{
-			Name:        "arc__failed",
-			Type:        nodegraph.FieldTypeNumber,
-			Color:       "red",
-			DisplayName: "Failed",
-		},
-		{
-			Name:        "arc__success",
-			Type:        nodegraph.FieldTypeNumber,
-			Color:       "green",
-			DisplayName: "Success",
-		},
-	},
+	Depth      int      `schema:"depth"`
+	Name       string   `schema:"name"`
+	Namespace  string   `schema:"namespace"`
+	Kind       string   `schema:"kind"`
+	Direction  string   `schema:"direction"`
+	From       int64    `schema:"from"`
+	To         int64    `schema:"to"`
+	View       string   `schema:"view"`
+	Roots      []string `schema:"root"`
+	Mode       string   `schema:"mode"`
+	Target     string   `schema:"target"`
+	Cluster    string   `schema:"cluster"`
+	Quantiles  string   `schema:"quantiles"`
+	Quantile   string   `schema:"quantile"`
+	RateWindow string   `schema:"rateWindow"`
+	Step       string   `schema:"step"`
+}
+
+// Spec returns the fields of the graphs returned by Graph for parameters.
+func (m Stats) Spec(parameters Parameters) (nodegraph.NodeFields, error) {
+	latency, err := parameters.latencySpec(m.Quantiles)
+	if err != nil {
+		return nodegraph.NodeFields{}, err
+	}
+
+	return latency.nodeFields(), nil
 }
 
 func (m Stats) Graph(ctx context.Context, parameters Parameters) (*nodegraph.Graph, error) {
+	parameters, err := m.validate(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	latency, err := parameters.latencySpec(m.Quantiles)
+	if err != nil {
+		return nil, err
+	}
+
 	nodeGraph := nodegraph.Graph{
-		Spec:  GraphSpec,
+		Spec:  latency.nodeFields(),
 		Nodes: []nodegraph.Node{},
 		Edges: []nodegraph.Edge{},
 	}
 
-	resource := parameters.graphResource()
-
 	targetDepth := 1
 	if parameters.Depth != 0 {
 		targetDepth = parameters.Depth
 	}
 
-	b, err := m.Server.NewBuilder().Build(ctx, parameters.From, parameters.To)
+	explicitRoots, err := parameters.explicitRoots()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create builder: %w", err)
+		return nil, err
 	}
 
-	root := b.Node(ctx, resource)
+	if parameters.Mode == "path" {
+		return m.path(ctx, parameters, latency, explicitRoots)
+	}
 
-	err = nodeGraph.AddNode(nodegraphNode(*root))
+	b, err := m.snapshot(ctx, parameters, latency)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add root node to graph: %w", err)
+		return nil, fmt.Errorf("failed to create builder: %w", err)
 	}
 
+	nodeGraph.Warnings = graph.Warnings(b)
+
 	seenNodes := map[string]bool{}
 	seenEdges := map[string]bool{}
 	currentDepth := 0
+	nodesToScan := []*graph.Node{}
+
+	rootIDs := map[string]bool{}
+	for _, resource := range explicitRoots {
+		rootIDs[graph.Node{Resource: resource}.ID()] = true
+	}
+
+	roots := explicitRoots
+	if len(roots) == 0 {
+		roots = parameters.allRoots(ctx, b)
+	}
 
-	seenNodes[root.ID()] = true
-	nodesToScan := []*graph.Node{root}
+	for _, resource := range roots {
+		root := b.Node(ctx, resource)
+		if seenNodes[root.ID()] {
+			continue
+		}
+
+		err = nodeGraph.AddNode(nodegraphNode(*root, rootIDs[root.ID()], latency))
+		if err != nil {
+			return nil, fmt.Errorf("failed to add root node to graph: %w", err)
+		}
+
+		seenNodes[root.ID()] = true
+		nodesToScan = append(nodesToScan, root)
+	}
 
 	var edgesFunc func(context.Context, *graph.Node) []graph.Edge
 
@@ -102,6 +143,9 @@ func (m Stats) Graph(ctx context.Context, parameters Parameters) (*nodegraph.Gra
 		edgesFunc = b.EdgesOf
 	}
 
+	// depthReached is the last depth where new nodes were found.
+	depthReached := 0
+
 	for currentDepth < targetDepth {
 		currentDepth++
 
@@ -115,7 +159,7 @@ func (m Stats) Graph(ctx context.Context, parameters Parameters) (*nodegraph.Gra
 					newNodesToScan = append(newNodesToScan, edge.Source)
 					seenNodes[edge.Source.ID()] = true
 
-					err = nodeGraph.AddNode(nodegraphNode(*edge.Source))
+					err = nodeGraph.AddNode(nodegraphNode(*edge.Source, false, latency))
 					if err != nil {
 						return nil, fmt.Errorf("failed to add node: %w", err)
 					}
@@ -125,7 +169,7 @@ func (m Stats) Graph(ctx context.Context, parameters Parameters) (*nodegraph.Gra
 					newNodesToScan = append(newNodesToScan, edge.Destination)
 					seenNodes[edge.Destination.ID()] = true
 
-					err = nodeGraph.AddNode(nodegraphNode(*edge.Destination))
+					err = nodeGraph.AddNode(nodegraphNode(*edge.Destination, false, latency))
 					if err != nil {
 						return nil, fmt.Errorf("failed to add node: %w", err)
 					}
@@ -134,7 +178,7 @@ func (m Stats) Graph(ctx context.Context, parameters Parameters) (*nodegraph.Gra
 				if ok := seenEdges[edge.ID()]; !ok {
 					seenEdges[edge.ID()] = true
 
-					err = nodeGraph.AddEdge(nodegraphEdge(edge))
+					err = nodeGraph.AddEdge(nodegraphEdge(edge, latency))
 					if err != nil {
 						return nil, fmt.Errorf("failed to add edge: %w", err)
 					}
@@ -142,65 +186,263 @@ func (m Stats) Graph(ctx context.Context, parameters Parameters) (*nodegraph.Gra
 			}
 		}
 
+		if len(newNodesToScan) > 0 {
+			depthReached = currentDepth
+		}
+
 		nodesToScan = newNodesToScan
 	}
 
+	metrics.GraphDepth.Observe(float64(depthReached))
+
+	for _, resource := range explicitRoots {
+		highlight(&nodeGraph, criticalPath(ctx, b, b.Node(ctx, resource), targetDepth, seenEdges, latency.selected))
+	}
+
 	return &nodeGraph, nil
 }
 
+func (m Stats) snapshot(ctx context.Context, parameters Parameters, latency latencySpec) (graph.Snapshot, error) {
+	rateWindow, err := parameters.duration(parameters.RateWindow, ErrInvalidRateWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	step, err := parameters.duration(parameters.Step, ErrInvalidStep)
+	if err != nil {
+		return nil, err
+	}
+
+	query := graph.Query{
+		From:       parameters.From,
+		To:         parameters.To,
+		View:       graph.WorkloadView,
+		Quantiles:  latency.quantiles,
+		RateWindow: rateWindow,
+		Step:       step,
+	}
+
+	if parameters.View == "service" {
+		query.View = graph.ServiceView
+	}
+
+	b, err := m.Source.Snapshot(ctx, query)
+	if err != nil {
+		return nil, &sourceError{err: err}
+	}
+
+	if parameters.View == "namespace" {
+		return newNamespaceSnapshot(ctx, b), nil
+	}
+
+	return b, nil
+}
+
+// duration parses the duration parameter s, of at least a second, zero when
+// empty, failing with errInvalid.
+func (p Parameters) duration(s string, errInvalid error) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("%w: %q", errInvalid, s)
+	}
+
+	return d, nil
+}
+
+// explicitRoots returns the requested root resources: the one described by
+// name, namespace and kind, if any, followed by every root parameter.
+func (p Parameters) explicitRoots() ([]graph.Resource, error) {
+	roots := []graph.Resource{}
+
+	if p.Name != "" {
+		roots = append(roots, p.graphResource())
+	}
+
+	for _, root := range p.Roots {
+		resource, ok := p.parseResource(root)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRoot, root)
+		}
+
+		roots = append(roots, resource)
+	}
+
+	return roots, nil
+}
+
+// parseResource parses a cluster/namespace/kind/name, namespace/kind/name or
+// namespace/name resource, defaulting the cluster and kind as graphResource
+// does.
+func (p Parameters) parseResource(s string) (graph.Resource, bool) {
+	parts := strings.Split(s, "/")
+
+	resource := Parameters{View: p.View, Cluster: p.Cluster}
+
+	switch len(parts) {
+	case 4: //nolint:gomnd
+		resource.Cluster, resource.Namespace, resource.Kind, resource.Name = parts[0], parts[1], parts[2], parts[3]
+	case 2: //nolint:gomnd
+		resource.Namespace, resource.Name = parts[0], parts[1]
+	case 3: //nolint:gomnd
+		resource.Namespace, resource.Kind, resource.Name = parts[0], parts[1], parts[2]
+	default:
+		return graph.Resource{}, false
+	}
+
+	if resource.Name == "" || !validKind(resource.Kind) {
+		return graph.Resource{}, false
+	}
+
+	return resource.graphResource(), true
+}
+
+// allRoots returns every resource of the snapshot, restricted to the comma
+// separated list of namespaces if any.
+func (p Parameters) allRoots(ctx context.Context, s graph.Snapshot) []graph.Resource {
+	namespaces := p.namespaces()
+	resources := []graph.Resource{}
+
+	for _, resource := range s.Resources(ctx) {
+		if len(namespaces) == 0 || namespaces[resource.Namespace] {
+			resources = append(resources, resource)
+		}
+	}
+
+	return resources
+}
+
+func (p Parameters) namespaces() map[string]bool {
+	namespaces := map[string]bool{}
+
+	for _, namespace := range strings.Split(p.Namespace, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace != "" {
+			namespaces[namespace] = true
+		}
+	}
+
+	return namespaces
+}
+
 func (p Parameters) graphResource() graph.Resource {
 	resource := graph.Resource{
 		Name:      p.Name,
 		Namespace: p.Namespace,
 		Kind:      graph.ResourceKindFromString(p.Kind),
+		Cluster:   p.Cluster,
+	}
+
+	switch p.View {
+	case "service":
+		if p.Kind == "" {
+			resource.Kind = graph.ServiceKind
+		}
+	case "namespace":
+		resource = namespaceResource(p.Cluster, p.Name)
 	}
 
 	return resource
 }
 
-func nodegraphEdge(edge graph.Edge) nodegraph.Edge {
-	return nodegraph.Edge{
-		"id":     edge.ID(),
-		"source": edge.Source.ID(),
-		"target": edge.Destination.ID(),
+func nodegraphEdge(edge graph.Edge, latency latencySpec) nodegraph.Edge {
+	percent := formatSuccessRate(edge.SuccessRate, edge.HasSuccessRate())
+	latencies, secondaryStat := latency.latencies(edge.Latencies)
+
+	nodegraphEdge := nodegraph.Edge{
+		"id":                  edge.ID(),
+		"source":              edge.Source.ID(),
+		"target":              edge.Destination.ID(),
+		"detail__successRate": percent,
+		"detail__volume":      formatVolume(edge.RequestRate),
+		"mainStat":            "SR: " + percent,
+		"secondaryStat":       secondaryStat,
+		"highlighted":         false,
+		"color":               "",
+	}
+
+	for name, value := range latencies {
+		nodegraphEdge[name] = value
 	}
+
+	return nodegraphEdge
 }
 
-func nodegraphNode(node graph.Node) nodegraph.Node {
+func nodegraphNode(node graph.Node, root bool, latency latencySpec) nodegraph.Node {
 	var failed float64 = 1
 
 	var success float64
 
-	percent := defaultUnknownValue
-	p95 := defaultUnknownValue
-	volume := defaultUnknownValue
-
-	if node.SuccessRate != 0 {
+	if node.HasSuccessRate() {
 		success = node.SuccessRate
 		failed = 1 - success
-		percent = fmt.Sprintf("%.2f%%", success*100) //nolint:gomnd
-	}
-
-	if node.LatencyP95 != 0 {
-		p95 = fmt.Sprintf("%.1fms", node.LatencyP95)
 	}
 
-	if node.RequestVolume != 0 {
-		volume = fmt.Sprintf("%.0frd/s", node.RequestVolume)
-	}
+	percent := formatSuccessRate(node.SuccessRate, node.HasSuccessRate())
+	latencies, secondaryStat := latency.latencies(node.Latencies)
+	volume := formatVolume(node.RequestVolume)
 
-	return nodegraph.Node{
+	nodegraphNode := nodegraph.Node{
 		"id":                  node.ID(),
-		"title":               fmt.Sprintf("%s/%s", node.Resource.Namespace, node.Resource.Name),
+		"title":               nodeTitle(node),
 		"arc__failed":         failed,
 		"arc__success":        success,
+		"arc__critical":       0.0,
 		"detail__type":        node.Resource.Kind.String(),
 		"detail__namespace":   node.Resource.Namespace,
 		"detail__name":        node.Resource.Name,
+		"detail__cluster":     node.Resource.Cluster,
 		"detail__successRate": percent,
-		"detail__latency_p95": p95,
 		"detail__volume":      volume,
+		"detail__root":        fmt.Sprintf("%t", root),
 		"mainStat":            "SR: " + percent,
-		"secondaryStat":       "p95: " + p95,
+		"secondaryStat":       secondaryStat,
+		"highlighted":         false,
 	}
+
+	for name, value := range latencies {
+		nodegraphNode[name] = value
+	}
+
+	return nodegraphNode
+}
+
+func nodeTitle(node graph.Node) string {
+	title := fmt.Sprintf("%s/%s", node.Resource.Namespace, node.Resource.Name)
+	if node.Resource.Namespace == "" || node.Resource.Kind == graph.NamespaceKind {
+		title = node.Resource.Name
+	}
+
+	if node.Resource.Cluster != "" {
+		return fmt.Sprintf("%s/%s", node.Resource.Cluster, title)
+	}
+
+	return title
+}
+
+func formatSuccessRate(rate float64, known bool) string {
+	if !known {
+		return defaultUnknownValue
+	}
+
+	return fmt.Sprintf("%.2f%%", rate*100) //nolint:gomnd
+}
+
+func formatLatency(latency float64) string {
+	if latency == 0 {
+		return defaultUnknownValue
+	}
+
+	return fmt.Sprintf("%.1fms", latency)
+}
+
+func formatVolume(volume float64) string {
+	if volume == 0 {
+		return defaultUnknownValue
+	}
+
+	return fmt.Sprintf("%.0frd/s", volume)
 }
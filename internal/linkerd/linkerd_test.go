@@ -0,0 +1,228 @@
+package linkerd
+
+import (
+	"context"
+	"errors"
+	"linkerd-nodegraph/internal/graph"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParametersRoots(t *testing.T) {
+	snapshot := fakeSnapshot{
+		nodes: []graph.Node{
+			{Resource: deployment("front", "web")},
+			{Resource: deployment("back", "api")},
+			{Resource: deployment("data", "db")},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		parameters Parameters
+		expected   []graph.Resource
+	}{
+		{
+			name:       "single root",
+			parameters: Parameters{Name: "web", Namespace: "front", Kind: "deployment"},
+			expected:   []graph.Resource{deployment("front", "web")},
+		},
+		{
+			name:       "several roots",
+			parameters: Parameters{Roots: []string{"front/deployment/web", "data/deployment/db"}},
+			expected:   []graph.Resource{deployment("front", "web"), deployment("data", "db")},
+		},
+		{
+			name:       "roots in clusters",
+			parameters: Parameters{Roots: []string{"east/front/deployment/web", "data/deployment/db"}, Cluster: "west"},
+			expected: []graph.Resource{
+				{Name: "web", Namespace: "front", Kind: graph.DeploymentKind, Cluster: "east"},
+				{Name: "db", Namespace: "data", Kind: graph.DeploymentKind, Cluster: "west"},
+			},
+		},
+		{
+			name:       "whole mesh",
+			parameters: Parameters{},
+			expected:   []graph.Resource{deployment("front", "web"), deployment("back", "api"), deployment("data", "db")},
+		},
+		{
+			name:       "restricted to namespaces",
+			parameters: Parameters{Namespace: "front, data"},
+			expected:   []graph.Resource{deployment("front", "web"), deployment("data", "db")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			roots, err := test.parameters.explicitRoots()
+			assert.Nil(t, err)
+
+			if len(roots) == 0 {
+				roots = test.parameters.allRoots(context.Background(), snapshot)
+			}
+
+			assert.Equal(t, test.expected, roots)
+		})
+	}
+}
+
+func Test_ParametersExplicitRoots(t *testing.T) {
+	parameters := Parameters{
+		Name:      "web",
+		Namespace: "front",
+		Roots:     []string{"back/statefulset/db", "back/api"},
+	}
+
+	roots, err := parameters.explicitRoots()
+	assert.Nil(t, err)
+	assert.Equal(t, []graph.Resource{
+		{Name: "web", Namespace: "front", Kind: graph.UndefinedKind},
+		{Name: "db", Namespace: "back", Kind: graph.StatefulsetKind},
+		{Name: "api", Namespace: "back", Kind: graph.UndefinedKind},
+	}, roots)
+
+	_, err = Parameters{Roots: []string{"web"}}.explicitRoots()
+	assert.True(t, errors.Is(err, ErrInvalidRoot))
+}
+
+type fakeSource struct {
+	snapshot fakeSnapshot
+	queries  []graph.Query
+}
+
+func (f *fakeSource) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	f.queries = append(f.queries, query)
+
+	return f.snapshot, nil
+}
+
+func Test_StatsGraph(t *testing.T) {
+	web := graph.Node{Resource: deployment("front", "web"), Latencies: graph.Latencies{0.95: 10}}
+	api := graph.Node{Resource: deployment("back", "api"), Latencies: graph.Latencies{0.95: 20}}
+	db := graph.Node{Resource: deployment("data", "db"), Latencies: graph.Latencies{0.95: 30}}
+	source := &fakeSource{snapshot: fakeSnapshot{
+		nodes: []graph.Node{web, api, db},
+		edges: []graph.Edge{
+			{Source: &web, Destination: &api, RequestRate: 1},
+			{Source: &api, Destination: &db, RequestRate: 1},
+		},
+	}}
+	stats := Stats{Source: source}
+
+	g, err := stats.Graph(context.Background(), Parameters{
+		Name: "web", Namespace: "front", Kind: "deployment", From: 1000, To: 2000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []graph.Query{{From: 1000, To: 2000, View: graph.WorkloadView, Quantiles: []float64{0.95}}}, source.queries)
+	assert.Len(t, g.Nodes, 2)
+	assert.Len(t, g.Edges, 1)
+	assert.Equal(t, "true", g.Nodes[0]["detail__root"])
+	assert.Equal(t, true, g.Edges[0]["highlighted"])
+
+	g, err = stats.Graph(context.Background(), Parameters{
+		Name: "web", Namespace: "front", Kind: "deployment", Depth: 2, Direction: "outbound",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, g.Nodes, 3)
+	assert.Len(t, g.Edges, 2)
+
+	g, err = stats.Graph(context.Background(), Parameters{
+		Name: "db", Namespace: "data", Kind: "deployment", Direction: "outbound",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, g.Nodes, 1)
+	assert.Len(t, g.Edges, 0)
+}
+
+func Test_StatsGraphRateWindow(t *testing.T) {
+	source := &fakeSource{}
+	stats := Stats{Source: source}
+
+	_, err := stats.Graph(context.Background(), Parameters{From: 1000, To: 2000, RateWindow: "5m", Step: "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, source.queries, 1) {
+		assert.Equal(t, 5*time.Minute, source.queries[0].RateWindow)
+		assert.Equal(t, time.Minute, source.queries[0].Step)
+	}
+
+	for _, window := range []string{"5", "-1m", "500ms"} {
+		_, err = stats.Graph(context.Background(), Parameters{RateWindow: window})
+		assert.True(t, errors.Is(err, ErrInvalidRateWindow), window)
+
+		_, err = stats.Graph(context.Background(), Parameters{Step: window})
+		assert.True(t, errors.Is(err, ErrInvalidStep), window)
+	}
+}
+
+func Test_StatsGraphWarnings(t *testing.T) {
+	web := graph.Node{Resource: deployment("front", "web")}
+	source := &fakeSource{snapshot: fakeSnapshot{
+		nodes:    []graph.Node{web},
+		warnings: []string{"latency unavailable: timeout"},
+	}}
+	stats := Stats{Source: source}
+
+	for _, parameters := range []Parameters{
+		{},
+		{View: "namespace"},
+		{Mode: "path", Roots: []string{"front/web"}, Target: "front/web"},
+	} {
+		g, err := stats.Graph(context.Background(), parameters)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []string{"latency unavailable: timeout"}, g.Warnings)
+		assert.Equal(t, "p95: N/A", g.Nodes[0]["secondaryStat"])
+	}
+}
+
+// failingSource fails every snapshot.
+type failingSource struct{}
+
+var errPrometheus = errors.New("prometheus unavailable")
+
+func (failingSource) Snapshot(ctx context.Context, query graph.Query) (graph.Snapshot, error) {
+	return nil, errPrometheus
+}
+
+func Test_StatsGraphErrors(t *testing.T) {
+	stats := Stats{Source: &fakeSource{}}
+
+	for _, tt := range []struct {
+		parameters Parameters
+		err        error
+	}{
+		{parameters: Parameters{Depth: -1}, err: ErrInvalidDepth},
+		{parameters: Parameters{From: 2000, To: 1000}, err: ErrInvalidTimeRange},
+		{parameters: Parameters{Name: "web", Namespace: "front", Kind: "deploy"}, err: ErrInvalidKind},
+		{parameters: Parameters{Roots: []string{"front/deploy/web"}}, err: ErrInvalidRoot},
+		{parameters: Parameters{Quantiles: "95"}, err: ErrInvalidQuantile},
+		{parameters: Parameters{Step: "1"}, err: ErrInvalidStep},
+		{parameters: Parameters{Mode: "path"}, err: ErrMissingSource},
+	} {
+		_, err := stats.Graph(context.Background(), tt.parameters)
+		assert.True(t, errors.Is(err, tt.err), err)
+		assert.True(t, errors.Is(err, ErrInvalidParameters), err)
+		assert.False(t, errors.Is(err, ErrSource), err)
+	}
+
+	_, err := Stats{Source: failingSource{}}.Graph(context.Background(), Parameters{})
+	assert.True(t, errors.Is(err, ErrSource))
+	assert.True(t, errors.Is(err, errPrometheus))
+	assert.False(t, errors.Is(err, ErrInvalidParameters))
+}
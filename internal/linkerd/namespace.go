@@ -0,0 +1,150 @@
+package linkerd
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+)
+
+// namespaceSnapshot collapses the resources of a snapshot into one node per
+// namespace. Resources without a namespace, such as external hosts, are kept
+// as they are, and traffic within a namespace is left out.
+type namespaceSnapshot struct {
+	snapshot graph.Snapshot
+	// resources are the namespaces and the resources without a namespace,
+	// in the order first seen.
+	resources []graph.Resource
+	// namespaceMembers are the resources merged in each namespace.
+	namespaceMembers map[graph.Resource][]graph.Resource
+}
+
+func newNamespaceSnapshot(ctx context.Context, s graph.Snapshot) namespaceSnapshot {
+	snapshot := namespaceSnapshot{
+		snapshot:         s,
+		resources:        []graph.Resource{},
+		namespaceMembers: map[graph.Resource][]graph.Resource{},
+	}
+	seen := map[graph.Resource]bool{}
+
+	for _, resource := range s.Resources(ctx) {
+		collapsed := snapshot.collapse(resource)
+		if collapsed.Kind == graph.NamespaceKind {
+			snapshot.namespaceMembers[collapsed] = append(snapshot.namespaceMembers[collapsed], resource)
+		}
+
+		if !seen[collapsed] {
+			seen[collapsed] = true
+			snapshot.resources = append(snapshot.resources, collapsed)
+		}
+	}
+
+	return snapshot
+}
+
+func (s namespaceSnapshot) Warnings() []string {
+	return graph.Warnings(s.snapshot)
+}
+
+func namespaceResource(cluster string, namespace string) graph.Resource {
+	return graph.Resource{
+		Name:      namespace,
+		Namespace: namespace,
+		Kind:      graph.NamespaceKind,
+		Cluster:   cluster,
+	}
+}
+
+// collapse returns the resource standing for resource in the graph.
+func (s namespaceSnapshot) collapse(resource graph.Resource) graph.Resource {
+	if resource.Namespace == "" {
+		return resource
+	}
+
+	return namespaceResource(resource.Cluster, resource.Namespace)
+}
+
+// members returns the resources of the underlying snapshot merged in resource.
+func (s namespaceSnapshot) members(resource graph.Resource) []graph.Resource {
+	if resource.Kind != graph.NamespaceKind {
+		return []graph.Resource{resource}
+	}
+
+	return s.namespaceMembers[resource]
+}
+
+// Resources returns the namespaces of the underlying resources, along with
+// the resources without a namespace.
+func (s namespaceSnapshot) Resources(ctx context.Context) []graph.Resource {
+	return s.resources
+}
+
+// Node returns the graph.Node of resource, summing the request volume of its
+// members and weighting their success rate and latency by volume.
+func (s namespaceSnapshot) Node(ctx context.Context, resource graph.Resource) *graph.Node {
+	if resource.Kind != graph.NamespaceKind {
+		return s.snapshot.Node(ctx, resource)
+	}
+
+	node := &graph.Node{Resource: resource}
+
+	for _, member := range s.members(resource) {
+		node.Merge(*s.snapshot.Node(ctx, member))
+	}
+
+	return node
+}
+
+func (s namespaceSnapshot) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+	nodes := map[graph.Resource]*graph.Node{}
+
+	for _, member := range s.members(node.Resource) {
+		for _, edge := range s.snapshot.UpstreamEdgesOf(ctx, s.snapshot.Node(ctx, member)) {
+			destination := s.collapse(edge.Destination.Resource)
+			if destination == node.Resource {
+				continue
+			}
+
+			if _, ok := nodes[destination]; !ok {
+				nodes[destination] = s.Node(ctx, destination)
+			}
+
+			edge.Source = node
+			edge.Destination = nodes[destination]
+			edges = append(edges, edge)
+		}
+	}
+
+	return graph.MergeEdges(edges)
+}
+
+func (s namespaceSnapshot) DownstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+	nodes := map[graph.Resource]*graph.Node{}
+
+	for _, member := range s.members(node.Resource) {
+		for _, edge := range s.snapshot.DownstreamEdgesOf(ctx, s.snapshot.Node(ctx, member)) {
+			source := s.collapse(edge.Source.Resource)
+			if source == node.Resource {
+				continue
+			}
+
+			if _, ok := nodes[source]; !ok {
+				nodes[source] = s.Node(ctx, source)
+			}
+
+			edge.Source = nodes[source]
+			edge.Destination = node
+			edges = append(edges, edge)
+		}
+	}
+
+	return graph.MergeEdges(edges)
+}
+
+func (s namespaceSnapshot) EdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+	edges = append(edges, s.UpstreamEdgesOf(ctx, node)...)
+	edges = append(edges, s.DownstreamEdgesOf(ctx, node)...)
+
+	return edges
+}
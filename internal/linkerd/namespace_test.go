@@ -0,0 +1,129 @@
+package linkerd
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSnapshot struct {
+	nodes    []graph.Node
+	edges    []graph.Edge
+	warnings []string
+}
+
+func (f fakeSnapshot) Warnings() []string {
+	return f.warnings
+}
+
+func (f fakeSnapshot) Node(ctx context.Context, resource graph.Resource) *graph.Node {
+	for i := range f.nodes {
+		if f.nodes[i].Resource == resource {
+			return &f.nodes[i]
+		}
+	}
+
+	return &graph.Node{Resource: resource}
+}
+
+func (f fakeSnapshot) Resources(ctx context.Context) []graph.Resource {
+	resources := []graph.Resource{}
+	for _, node := range f.nodes {
+		resources = append(resources, node.Resource)
+	}
+
+	return resources
+}
+
+func (f fakeSnapshot) UpstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, edge := range f.edges {
+		if edge.Source.Resource == node.Resource {
+			edges = append(edges, edge)
+		}
+	}
+
+	return edges
+}
+
+func (f fakeSnapshot) DownstreamEdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	edges := []graph.Edge{}
+
+	for _, edge := range f.edges {
+		if edge.Destination.Resource == node.Resource {
+			edges = append(edges, edge)
+		}
+	}
+
+	return edges
+}
+
+func (f fakeSnapshot) EdgesOf(ctx context.Context, node *graph.Node) []graph.Edge {
+	return append(f.UpstreamEdgesOf(ctx, node), f.DownstreamEdgesOf(ctx, node)...)
+}
+
+func deployment(namespace string, name string) graph.Resource {
+	return graph.Resource{Name: name, Namespace: namespace, Kind: graph.DeploymentKind}
+}
+
+func Test_NamespaceSnapshot(t *testing.T) {
+	web := graph.Node{Resource: deployment("front", "web"), RequestVolume: 3, SuccessRate: 1, Latencies: graph.Latencies{0.95: 10}}
+	auth := graph.Node{Resource: deployment("front", "auth"), RequestVolume: 1, SuccessRate: 0.5, Latencies: graph.Latencies{0.95: 50}}
+	api := graph.Node{Resource: deployment("back", "api"), RequestVolume: 4, SuccessRate: 1, Latencies: graph.Latencies{0.95: 5}}
+	snapshot := fakeSnapshot{
+		nodes: []graph.Node{web, auth, api},
+		edges: []graph.Edge{
+			{Source: &web, Destination: &auth, RequestRate: 1},
+			{Source: &web, Destination: &api, RequestRate: 2, SuccessRate: 1, Latencies: graph.Latencies{0.95: 4}},
+			{Source: &auth, Destination: &api, RequestRate: 2, SuccessRate: 0.5, Latencies: graph.Latencies{0.95: 8}},
+		},
+	}
+
+	namespaces := newNamespaceSnapshot(context.Background(), snapshot)
+
+	front := namespaces.Node(context.Background(), namespaceResource("", "front"))
+	assert.Equal(t, 4.0, front.RequestVolume)
+	assert.Equal(t, 0.875, front.SuccessRate)
+	assert.Equal(t, 20.0, front.Latencies[0.95])
+
+	edges := namespaces.UpstreamEdgesOf(context.Background(), front)
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, "back__back__namespace", edges[0].Destination.ID())
+		assert.Equal(t, 4.0, edges[0].RequestRate)
+		assert.Equal(t, 0.75, edges[0].SuccessRate)
+		assert.Equal(t, 6.0, edges[0].Latencies[0.95])
+	}
+
+	back := namespaces.Node(context.Background(), namespaceResource("", "back"))
+
+	edges = namespaces.DownstreamEdgesOf(context.Background(), back)
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, "front__front__namespace", edges[0].Source.ID())
+		assert.Equal(t, 4.0, edges[0].RequestRate)
+	}
+}
+
+func Test_NamespaceSnapshotSuccessRate(t *testing.T) {
+	web := graph.Node{Resource: deployment("front", "web"), RequestVolume: 3, SuccessRate: 1}
+	auth := graph.Node{Resource: deployment("front", "auth"), RequestVolume: 1, SuccessRateKnown: true}
+	idle := graph.Node{Resource: deployment("front", "idle")}
+	snapshot := newNamespaceSnapshot(context.Background(), fakeSnapshot{nodes: []graph.Node{web, auth, idle}})
+
+	front := snapshot.Node(context.Background(), namespaceResource("", "front"))
+	assert.True(t, front.HasSuccessRate())
+	assert.Equal(t, 0.75, front.SuccessRate)
+
+	auth.SuccessRateKnown = false
+	snapshot = newNamespaceSnapshot(context.Background(), fakeSnapshot{nodes: []graph.Node{web, auth, idle}})
+
+	front = snapshot.Node(context.Background(), namespaceResource("", "front"))
+	assert.Equal(t, 1.0, front.SuccessRate)
+
+	snapshot = newNamespaceSnapshot(context.Background(), fakeSnapshot{nodes: []graph.Node{idle}})
+
+	front = snapshot.Node(context.Background(), namespaceResource("", "front"))
+	assert.False(t, front.HasSuccessRate())
+}
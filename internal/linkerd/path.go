@@ -0,0 +1,163 @@
+package linkerd
+
+import (
+	"context"
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/nodegraph"
+)
+
+const defaultMaxPathLength = 5
+
+var ErrMissingSource = newParameterError("path mode requires a source resource")
+
+// path returns the graph made of the nodes and edges lying on the paths, in
+// the direction of traffic, going from any of sources to the target resource
+// in at most depth hops.
+func (m Stats) path(
+	ctx context.Context, parameters Parameters, latency latencySpec, sources []graph.Resource,
+) (*nodegraph.Graph, error) {
+	if len(sources) == 0 {
+		return nil, ErrMissingSource
+	}
+
+	targetResource, ok := parameters.parseResource(parameters.Target)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTarget, parameters.Target)
+	}
+
+	maxLength := defaultMaxPathLength
+	if parameters.Depth != 0 {
+		maxLength = parameters.Depth
+	}
+
+	b, err := m.snapshot(ctx, parameters, latency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create builder: %w", err)
+	}
+
+	nodeGraph, err := pathGraph(ctx, b, sources, targetResource, maxLength, latency)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeGraph.Warnings = graph.Warnings(b)
+
+	return nodeGraph, nil
+}
+
+// pathGraph returns the graph of the paths of snapshot b going from any of
+// sources to targetResource in at most maxLength hops, showing the latencies
+// described by latency.
+func pathGraph(
+	ctx context.Context,
+	b graph.Snapshot,
+	sources []graph.Resource,
+	targetResource graph.Resource,
+	maxLength int,
+	latency latencySpec,
+) (*nodegraph.Graph, error) {
+	nodeGraph := nodegraph.Graph{
+		Spec:  latency.nodeFields(),
+		Nodes: []nodegraph.Node{},
+		Edges: []nodegraph.Edge{},
+	}
+
+	sourceNodes := []*graph.Node{}
+	for _, resource := range sources {
+		sourceNodes = append(sourceNodes, b.Node(ctx, resource))
+	}
+
+	target := b.Node(ctx, targetResource)
+
+	fromSources, walked := distances(ctx, sourceNodes, b.UpstreamEdgesOf, func(edge graph.Edge) *graph.Node {
+		return edge.Destination
+	}, maxLength)
+
+	toTarget, _ := distances(ctx, []*graph.Node{target}, b.DownstreamEdgesOf, func(edge graph.Edge) *graph.Node {
+		return edge.Source
+	}, maxLength)
+
+	seenNodes := map[string]bool{}
+
+	addNode := func(node *graph.Node, root bool) error {
+		if seenNodes[node.ID()] {
+			return nil
+		}
+
+		seenNodes[node.ID()] = true
+
+		return nodeGraph.AddNode(nodegraphNode(*node, root, latency))
+	}
+
+	for _, node := range append(sourceNodes, target) {
+		if err := addNode(node, true); err != nil {
+			return nil, fmt.Errorf("failed to add root node to graph: %w", err)
+		}
+	}
+
+	for _, edge := range walked {
+		toDestination, ok := toTarget[edge.Destination.ID()]
+		if !ok || fromSources[edge.Source.ID()]+1+toDestination > maxLength {
+			continue
+		}
+
+		if err := addNode(edge.Source, false); err != nil {
+			return nil, fmt.Errorf("failed to add node: %w", err)
+		}
+
+		if err := addNode(edge.Destination, false); err != nil {
+			return nil, fmt.Errorf("failed to add node: %w", err)
+		}
+
+		if err := nodeGraph.AddEdge(nodegraphEdge(edge, latency)); err != nil {
+			return nil, fmt.Errorf("failed to add edge: %w", err)
+		}
+	}
+
+	return &nodeGraph, nil
+}
+
+// distances walks from start along the edges returned by edgesFunc, up to
+// maxLength hops away. It returns the number of hops to every node reached
+// and every edge walked, once.
+func distances(
+	ctx context.Context,
+	start []*graph.Node,
+	edgesFunc func(context.Context, *graph.Node) []graph.Edge,
+	next func(graph.Edge) *graph.Node,
+	maxLength int,
+) (map[string]int, []graph.Edge) {
+	hops := map[string]int{}
+	seenEdges := map[string]bool{}
+	walked := []graph.Edge{}
+
+	for _, node := range start {
+		hops[node.ID()] = 0
+	}
+
+	nodesToScan := start
+
+	for depth := 1; depth <= maxLength; depth++ {
+		newNodesToScan := []*graph.Node{}
+
+		for _, node := range nodesToScan {
+			for _, edge := range edgesFunc(ctx, node) {
+				if !seenEdges[edge.ID()] {
+					seenEdges[edge.ID()] = true
+					walked = append(walked, edge)
+				}
+
+				nextNode := next(edge)
+				if _, ok := hops[nextNode.ID()]; !ok {
+					hops[nextNode.ID()] = depth
+					newNodesToScan = append(newNodesToScan, nextNode)
+				}
+			}
+		}
+
+		nodesToScan = newNodesToScan
+	}
+
+	return hops, walked
+}
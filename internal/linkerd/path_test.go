@@ -0,0 +1,58 @@
+package linkerd
+
+import (
+	"context"
+	"linkerd-nodegraph/internal/graph"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PathGraph(t *testing.T) {
+	frontend := graph.Node{Resource: deployment("shop", "frontend")}
+	checkout := graph.Node{Resource: deployment("shop", "checkout")}
+	cart := graph.Node{Resource: deployment("shop", "cart")}
+	payments := graph.Node{Resource: deployment("shop", "payments")}
+	paymentsDB := graph.Node{Resource: deployment("shop", "payments-db")}
+	search := graph.Node{Resource: deployment("shop", "search")}
+	snapshot := fakeSnapshot{
+		nodes: []graph.Node{frontend, checkout, cart, payments, paymentsDB, search},
+		edges: []graph.Edge{
+			{Source: &frontend, Destination: &checkout},
+			{Source: &frontend, Destination: &search},
+			{Source: &checkout, Destination: &payments},
+			{Source: &checkout, Destination: &cart},
+			{Source: &cart, Destination: &payments},
+			{Source: &payments, Destination: &paymentsDB},
+		},
+	}
+
+	nodeIDs := func(maxLength int) ([]string, int) {
+		g, err := pathGraph(context.Background(), snapshot,
+			[]graph.Resource{frontend.Resource}, paymentsDB.Resource, maxLength, defaultLatency)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for _, node := range g.Nodes {
+			ids = append(ids, node["id"].(string))
+		}
+
+		return ids, len(g.Edges)
+	}
+
+	ids, edges := nodeIDs(4)
+	assert.ElementsMatch(t, []string{
+		frontend.ID(), checkout.ID(), cart.ID(), payments.ID(), paymentsDB.ID(),
+	}, ids)
+	assert.Equal(t, 5, edges)
+
+	ids, edges = nodeIDs(3)
+	assert.ElementsMatch(t, []string{frontend.ID(), checkout.ID(), payments.ID(), paymentsDB.ID()}, ids)
+	assert.Equal(t, 3, edges)
+
+	ids, edges = nodeIDs(2)
+	assert.ElementsMatch(t, []string{frontend.ID(), paymentsDB.ID()}, ids)
+	assert.Equal(t, 0, edges)
+}
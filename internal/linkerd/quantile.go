@@ -0,0 +1,193 @@
+package linkerd
+
+import (
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"linkerd-nodegraph/internal/nodegraph"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidQuantile = newParameterError("quantiles must be numbers between 0 and 1 exclusive")
+
+// latencySpec describes the latency quantiles shown in a graph: every one of
+// quantiles gets its detail field, and selected is the secondary stat.
+type latencySpec struct {
+	quantiles []float64
+	selected  float64
+}
+
+// latencySpec returns the quantiles asked for by the comma separated
+// quantiles parameter, or defaults when empty, and the one selected by the
+// quantile parameter, the highest one by default. The selected quantile is
+// added to the others when missing.
+func (p Parameters) latencySpec(defaults []float64) (latencySpec, error) {
+	quantiles := []float64{}
+
+	for _, s := range strings.Split(p.Quantiles, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		quantile, err := parseQuantile(s)
+		if err != nil {
+			return latencySpec{}, err
+		}
+
+		quantiles = append(quantiles, quantile)
+	}
+
+	if len(quantiles) == 0 {
+		for _, quantile := range defaults {
+			if quantile <= 0 || quantile >= 1 {
+				return latencySpec{}, fmt.Errorf("%w: %v", ErrInvalidQuantile, quantile)
+			}
+
+			quantiles = append(quantiles, quantile)
+		}
+	}
+
+	if len(quantiles) == 0 {
+		quantiles = append(quantiles, graph.DefaultQuantile)
+	}
+
+	if p.Quantile == "" {
+		quantiles = uniqueSorted(quantiles)
+
+		return latencySpec{quantiles: quantiles, selected: quantiles[len(quantiles)-1]}, nil
+	}
+
+	selected, err := parseQuantile(p.Quantile)
+	if err != nil {
+		return latencySpec{}, err
+	}
+
+	return latencySpec{quantiles: uniqueSorted(append(quantiles, selected)), selected: selected}, nil
+}
+
+func parseQuantile(s string) (float64, error) {
+	quantile, err := strconv.ParseFloat(s, 64)
+	if err != nil || quantile <= 0 || quantile >= 1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidQuantile, s)
+	}
+
+	return quantile, nil
+}
+
+func uniqueSorted(quantiles []float64) []float64 {
+	sort.Float64s(quantiles)
+
+	unique := quantiles[:0]
+
+	for i, quantile := range quantiles {
+		if i == 0 || quantile != quantiles[i-1] {
+			unique = append(unique, quantile)
+		}
+	}
+
+	return unique
+}
+
+// quantileName names quantile after its percentile, such as p95 for 0.95 or
+// p99_9 for 0.999.
+func quantileName(quantile float64) string {
+	digits := strings.TrimPrefix(strconv.FormatFloat(quantile, 'f', -1, 64), "0.")
+	for len(digits) < 2 {
+		digits += "0"
+	}
+
+	if len(digits) == 2 { //nolint:gomnd
+		return "p" + digits
+	}
+
+	return "p" + digits[:2] + "_" + digits[2:]
+}
+
+// latencyField is the name of the detail field showing quantile.
+func latencyField(quantile float64) string {
+	return "detail__latency_" + quantileName(quantile)
+}
+
+// latencyFields returns the detail fields of the quantiles of l.
+func (l latencySpec) latencyFields() []nodegraph.Field {
+	fields := make([]nodegraph.Field, 0, len(l.quantiles))
+
+	for _, quantile := range l.quantiles {
+		fields = append(fields, nodegraph.Field{
+			Name:        latencyField(quantile),
+			Type:        nodegraph.FieldTypeString,
+			DisplayName: quantileName(quantile),
+		})
+	}
+
+	return fields
+}
+
+// latencies returns the detail fields of latencies, by name, and the
+// secondary stat showing the selected quantile.
+func (l latencySpec) latencies(latencies graph.Latencies) (map[string]string, string) {
+	fields := map[string]string{}
+
+	for _, quantile := range l.quantiles {
+		fields[latencyField(quantile)] = formatLatency(latencies[quantile])
+	}
+
+	return fields, quantileName(l.selected) + ": " + formatLatency(latencies[l.selected])
+}
+
+func (l latencySpec) nodeFields() nodegraph.NodeFields {
+	edge := []nodegraph.Field{
+		{Name: "id", Type: nodegraph.FieldTypeString},
+		{Name: "source", Type: nodegraph.FieldTypeString},
+		{Name: "target", Type: nodegraph.FieldTypeString},
+		{Name: "mainStat", Type: nodegraph.FieldTypeString, DisplayName: "Success Rate"},
+		{Name: "secondaryStat", Type: nodegraph.FieldTypeString, DisplayName: "Latency"},
+		{Name: "detail__successRate", Type: nodegraph.FieldTypeString, DisplayName: "Success Rate"},
+	}
+	edge = append(edge, l.latencyFields()...)
+	edge = append(edge,
+		nodegraph.Field{Name: "detail__volume", Type: nodegraph.FieldTypeString, DisplayName: "Request volume"},
+		nodegraph.Field{Name: "highlighted", Type: nodegraph.FieldTypeBoolean},
+		nodegraph.Field{Name: "color", Type: nodegraph.FieldTypeString},
+	)
+
+	node := []nodegraph.Field{
+		{Name: "id", Type: nodegraph.FieldTypeString},
+		{Name: "title", Type: nodegraph.FieldTypeString, DisplayName: "Resource"},
+		{Name: "mainStat", Type: nodegraph.FieldTypeString, DisplayName: "Success Rate"},
+		{Name: "secondaryStat", Type: nodegraph.FieldTypeString, DisplayName: "Latency"},
+		{Name: "detail__type", Type: nodegraph.FieldTypeString, DisplayName: "Type"},
+		{Name: "detail__namespace", Type: nodegraph.FieldTypeString, DisplayName: "Namespace"},
+		{Name: "detail__name", Type: nodegraph.FieldTypeString, DisplayName: "Name"},
+		{Name: "detail__cluster", Type: nodegraph.FieldTypeString, DisplayName: "Cluster"},
+		{Name: "detail__successRate", Type: nodegraph.FieldTypeString, DisplayName: "Success Rate"},
+	}
+	node = append(node, l.latencyFields()...)
+	node = append(node,
+		nodegraph.Field{Name: "detail__volume", Type: nodegraph.FieldTypeString, DisplayName: "Request volume"},
+		nodegraph.Field{Name: "detail__root", Type: nodegraph.FieldTypeString, DisplayName: "Root"},
+		nodegraph.Field{Name: "highlighted", Type: nodegraph.FieldTypeBoolean},
+		nodegraph.Field{
+			Name:        "arc__failed",
+			Type:        nodegraph.FieldTypeNumber,
+			Color:       "red",
+			DisplayName: "Failed",
+		},
+		nodegraph.Field{
+			Name:        "arc__success",
+			Type:        nodegraph.FieldTypeNumber,
+			Color:       "green",
+			DisplayName: "Success",
+		},
+		nodegraph.Field{
+			Name:        "arc__critical",
+			Type:        nodegraph.FieldTypeNumber,
+			Color:       criticalPathColor,
+			DisplayName: "Critical path",
+		},
+	)
+
+	return nodegraph.NodeFields{Edge: edge, Node: node}
+}
@@ -0,0 +1,116 @@
+package linkerd
+
+import (
+	"context"
+	"errors"
+	"linkerd-nodegraph/internal/graph"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultLatency = latencySpec{quantiles: []float64{0.95}, selected: 0.95}
+
+func Test_ParametersLatencySpec(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters Parameters
+		defaults   []float64
+		want       latencySpec
+		err        error
+	}{
+		{
+			name: "default quantile",
+			want: defaultLatency,
+		},
+		{
+			name:     "configured quantiles",
+			defaults: []float64{0.99, 0.5},
+			want:     latencySpec{quantiles: []float64{0.5, 0.99}, selected: 0.99},
+		},
+		{
+			name:       "requested quantiles",
+			parameters: Parameters{Quantiles: "0.5, 0.99,0.5"},
+			defaults:   []float64{0.95},
+			want:       latencySpec{quantiles: []float64{0.5, 0.99}, selected: 0.99},
+		},
+		{
+			name:       "selected quantile",
+			parameters: Parameters{Quantiles: "0.5,0.99", Quantile: "0.5"},
+			want:       latencySpec{quantiles: []float64{0.5, 0.99}, selected: 0.5},
+		},
+		{
+			name:       "selected quantile not requested",
+			parameters: Parameters{Quantile: "0.999"},
+			want:       latencySpec{quantiles: []float64{0.95, 0.999}, selected: 0.999},
+		},
+		{
+			name:       "invalid quantile",
+			parameters: Parameters{Quantiles: "0.5,95"},
+			err:        ErrInvalidQuantile,
+		},
+		{
+			name:       "invalid selected quantile",
+			parameters: Parameters{Quantile: "p99"},
+			err:        ErrInvalidQuantile,
+		},
+		{
+			name:     "invalid configured quantile",
+			defaults: []float64{1},
+			err:      ErrInvalidQuantile,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := test.parameters.latencySpec(test.defaults)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, test.want, spec)
+		})
+	}
+}
+
+func Test_QuantileName(t *testing.T) {
+	for quantile, name := range map[float64]string{0.5: "p50", 0.95: "p95", 0.99: "p99", 0.999: "p99_9", 0.05: "p05"} {
+		assert.Equal(t, name, quantileName(quantile))
+	}
+}
+
+func Test_StatsGraphQuantiles(t *testing.T) {
+	web := graph.Node{Resource: deployment("front", "web"), Latencies: graph.Latencies{0.5: 2, 0.99: 40}}
+	api := graph.Node{Resource: deployment("back", "api")}
+	source := &fakeSource{snapshot: fakeSnapshot{
+		nodes: []graph.Node{web, api},
+		edges: []graph.Edge{
+			{Source: &web, Destination: &api, RequestRate: 1, Latencies: graph.Latencies{0.5: 1, 0.99: 30}},
+		},
+	}}
+	stats := Stats{Source: source, Quantiles: []float64{0.95}}
+
+	g, err := stats.Graph(context.Background(), Parameters{
+		Name: "web", Namespace: "front", Kind: "deployment", Quantiles: "0.5,0.99", Quantile: "0.5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []float64{0.5, 0.99}, source.queries[0].Quantiles)
+	assert.Equal(t, "2.0ms", g.Nodes[0]["detail__latency_p50"])
+	assert.Equal(t, "40.0ms", g.Nodes[0]["detail__latency_p99"])
+	assert.Equal(t, "p50: 2.0ms", g.Nodes[0]["secondaryStat"])
+	assert.Equal(t, "p50: 1.0ms", g.Edges[0]["secondaryStat"])
+	assert.Equal(t, "N/A", g.Nodes[1]["detail__latency_p99"])
+
+	spec, err := stats.Spec(Parameters{Quantiles: "0.5,0.99"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, g.Spec, spec)
+}
@@ -0,0 +1,82 @@
+package linkerd
+
+import (
+	"fmt"
+	"linkerd-nodegraph/internal/graph"
+	"time"
+)
+
+// DefaultTimeRange is the time range of the requests giving neither from nor
+// to, ending now.
+const DefaultTimeRange = time.Hour
+
+var (
+	ErrInvalidKind      = newParameterError("kind must be a workload kind such as deployment, or service")
+	ErrInvalidDepth     = newParameterError("depth must be positive and at most the maximum depth")
+	ErrInvalidDirection = newParameterError("direction must be inbound, outbound or both")
+	ErrInvalidView      = newParameterError("view must be workload, service or namespace")
+	ErrInvalidMode      = newParameterError("mode must be graph or path")
+	ErrInvalidTimeRange = newParameterError("from and to must both be given, in milliseconds since epoch, from first")
+	ErrTimeRangeTooLong = newParameterError("time range must be at most the maximum time range")
+)
+
+// Values accepted by the enumerated parameters, the empty string standing for
+// the default one.
+var (
+	directions = map[string]bool{"": true, "both": true, "inbound": true, "outbound": true}
+	views      = map[string]bool{"": true, "workload": true, "service": true, "namespace": true}
+	modes      = map[string]bool{"": true, "graph": true, "path": true}
+)
+
+// validate checks the parameters that are not parsed on their own against
+// the limits of m, and returns them with the default time range when they
+// have none.
+func (m Stats) validate(p Parameters) (Parameters, error) {
+	if p.Depth < 0 || (m.MaxDepth > 0 && p.Depth > m.MaxDepth) {
+		return p, fmt.Errorf("%w: %d", ErrInvalidDepth, p.Depth)
+	}
+
+	if !directions[p.Direction] {
+		return p, fmt.Errorf("%w: %q", ErrInvalidDirection, p.Direction)
+	}
+
+	if !views[p.View] {
+		return p, fmt.Errorf("%w: %q", ErrInvalidView, p.View)
+	}
+
+	if !modes[p.Mode] {
+		return p, fmt.Errorf("%w: %q", ErrInvalidMode, p.Mode)
+	}
+
+	if !validKind(p.Kind) {
+		return p, fmt.Errorf("%w: %q", ErrInvalidKind, p.Kind)
+	}
+
+	if p.From == 0 && p.To == 0 {
+		now := time.Now
+		if m.now != nil {
+			now = m.now
+		}
+
+		p.To = now().UnixMilli()
+		p.From = p.To - DefaultTimeRange.Milliseconds()
+	}
+
+	if p.From <= 0 || p.To <= 0 || p.From > p.To {
+		return p, fmt.Errorf("%w: from %d, to %d", ErrInvalidTimeRange, p.From, p.To)
+	}
+
+	if m.MaxTimeRange > 0 && p.To-p.From > m.MaxTimeRange.Milliseconds() {
+		timeRange := time.Duration(p.To-p.From) * time.Millisecond
+
+		return p, fmt.Errorf("%w: %s > %s", ErrTimeRangeTooLong, timeRange, m.MaxTimeRange)
+	}
+
+	return p, nil
+}
+
+// validKind reports whether kind is empty, standing for the default kind, or
+// a known kind.
+func validKind(kind string) bool {
+	return kind == "" || graph.ResourceKindFromString(kind) != graph.UndefinedKind
+}
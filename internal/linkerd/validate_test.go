@@ -0,0 +1,70 @@
+package linkerd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StatsValidate(t *testing.T) {
+	now := time.Unix(100000, 0)
+	stats := Stats{MaxDepth: 5, MaxTimeRange: 24 * time.Hour, now: func() time.Time { return now }}
+	hour := time.Hour.Milliseconds()
+
+	tests := []struct {
+		name       string
+		parameters Parameters
+		want       Parameters
+		err        error
+	}{
+		{
+			name:       "default time range",
+			parameters: Parameters{},
+			want:       Parameters{From: now.UnixMilli() - hour, To: now.UnixMilli()},
+		},
+		{
+			name: "every parameter",
+			parameters: Parameters{
+				Depth: 5, Kind: "statefulset", Direction: "inbound", View: "service", Mode: "path", From: 1, To: 24 * hour,
+			},
+			want: Parameters{
+				Depth: 5, Kind: "statefulset", Direction: "inbound", View: "service", Mode: "path", From: 1, To: 24 * hour,
+			},
+		},
+		{name: "negative depth", parameters: Parameters{Depth: -1}, err: ErrInvalidDepth},
+		{name: "deep", parameters: Parameters{Depth: 6}, err: ErrInvalidDepth},
+		{name: "unknown direction", parameters: Parameters{Direction: "up"}, err: ErrInvalidDirection},
+		{name: "unknown view", parameters: Parameters{View: "pod"}, err: ErrInvalidView},
+		{name: "unknown mode", parameters: Parameters{Mode: "tree"}, err: ErrInvalidMode},
+		{name: "unknown kind", parameters: Parameters{Kind: "deploy"}, err: ErrInvalidKind},
+		{name: "missing from", parameters: Parameters{To: hour}, err: ErrInvalidTimeRange},
+		{name: "missing to", parameters: Parameters{From: hour}, err: ErrInvalidTimeRange},
+		{name: "from after to", parameters: Parameters{From: 2 * hour, To: hour}, err: ErrInvalidTimeRange},
+		{name: "long time range", parameters: Parameters{From: 1, To: 25 * hour}, err: ErrTimeRangeTooLong},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stats.validate(tt.parameters)
+			if tt.err != nil {
+				assert.True(t, errors.Is(err, tt.err), err)
+				assert.True(t, errors.Is(err, ErrInvalidParameters), err)
+
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_StatsValidateUnbounded(t *testing.T) {
+	_, err := Stats{}.validate(Parameters{Depth: 100, From: 1, To: 365 * 24 * time.Hour.Milliseconds()})
+	assert.NoError(t, err)
+}
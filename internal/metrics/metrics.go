@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "nodegraph"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by handler and status code.",
+	}, []string{"handler", "code"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the HTTP requests served, by handler and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler", "code"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "prometheus_query_duration_seconds",
+		Help:      "Duration of the queries sent to Prometheus, by query and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query", "status"})
+
+	// GraphNodes is the number of nodes of the graphs returned.
+	GraphNodes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graph_nodes",
+		Help:      "Number of nodes of the graphs returned.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	})
+
+	// GraphEdges is the number of edges of the graphs returned.
+	GraphEdges = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graph_edges",
+		Help:      "Number of edges of the graphs returned.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	})
+
+	// GraphDepth is the depth reached by the traversals of the graphs
+	// returned.
+	GraphDepth = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graph_depth_reached",
+		Help:      "Depth reached by the traversal of the graphs returned.",
+		Buckets:   prometheus.LinearBuckets(0, 1, 11),
+	})
+
+	// CacheRequests counts the snapshots asked to the cache, by result: hit
+	// when cached, shared when joining a build in flight, and miss.
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Snapshots asked to the cache, by result.",
+	}, []string{"result"})
+)
+
+// Handler serves the metrics of the default registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// InstrumentHandler counts the requests served by handler, named name, and
+// observes their duration.
+func InstrumentHandler(name string, handler http.HandlerFunc) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+
+	return promhttp.InstrumentHandlerCounter(
+		httpRequests.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(httpRequestDuration.MustCurryWith(labels), handler),
+	)
+}
+
+// ObserveQuery observes the duration of the Prometheus query named query,
+// started at start, which failed when err is not nil.
+func ObserveQuery(query string, start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+
+	queryDuration.WithLabelValues(query, status).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,38 @@
+package metrics_test
+
+import (
+	"errors"
+	"linkerd-nodegraph/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errQuery = errors.New("query failed")
+
+func TestHandlerServesMetrics(t *testing.T) {
+	handler := metrics.InstrumentHandler("test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	metrics.ObserveQuery("edges", time.Now(), nil)
+	metrics.ObserveQuery("latency", time.Now(), errQuery)
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := recorder.Body.String()
+	for _, series := range []string{
+		`nodegraph_http_requests_total{code="400",handler="test"} 1`,
+		`nodegraph_http_request_duration_seconds_count{code="400",handler="test"} 1`,
+		`nodegraph_prometheus_query_duration_seconds_count{query="edges",status="success"} 1`,
+		`nodegraph_prometheus_query_duration_seconds_count{query="latency",status="error"} 1`,
+	} {
+		assert.True(t, strings.Contains(body, series), series)
+	}
+}
@@ -13,6 +13,7 @@ type FieldType int
 const (
 	FieldTypeString FieldType = iota
 	FieldTypeNumber
+	FieldTypeBoolean
 )
 
 type Field struct {
@@ -36,6 +37,8 @@ type Graph struct {
 	Spec  NodeFields `json:"-"`
 	Nodes []Node     `json:"nodes"`
 	Edges []Edge     `json:"edges"`
+	// Warnings tell why some stats may be missing.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 func (f FieldType) String() string {
@@ -44,6 +47,8 @@ func (f FieldType) String() string {
 		return "string"
 	case FieldTypeNumber:
 		return "number"
+	case FieldTypeBoolean:
+		return "boolean"
 	}
 
 	return "unknown"
@@ -111,6 +116,10 @@ func validItem(item map[string]interface{}, fields []Field) bool {
 			}
 
 			return false
+		case FieldTypeBoolean:
+			if _, ok := item[field.Name].(bool); !ok {
+				return false
+			}
 		}
 	}
 
@@ -13,6 +13,7 @@ var fields = nodegraph.NodeFields{
 	Edge: []nodegraph.Field{
 		{Name: "foo", Type: nodegraph.FieldTypeString},
 		{Name: "bar", Type: nodegraph.FieldTypeNumber},
+		{Name: "baz", Type: nodegraph.FieldTypeBoolean},
 	},
 	Node: []nodegraph.Field{
 		{Name: "foo", Type: nodegraph.FieldTypeString},
@@ -33,7 +34,7 @@ var fields = nodegraph.NodeFields{
 }
 
 func Test_NodeFieldsMarshall(t *testing.T) {
-	const expected = `{"nodes_fields":[{"field_name":"foo","type":"string"},{"field_name":"bar","type":"number"},{"field_name":"arc__foo","type":"number","color":"foo","displayName":"foo"},{"field_name":"arc__bar","type":"string","color":"bar","displayName":"bar"}],"edges_fields":[{"field_name":"foo","type":"string"},{"field_name":"bar","type":"number"}]}`
+	const expected = `{"nodes_fields":[{"field_name":"foo","type":"string"},{"field_name":"bar","type":"number"},{"field_name":"arc__foo","type":"number","color":"foo","displayName":"foo"},{"field_name":"arc__bar","type":"string","color":"bar","displayName":"bar"}],"edges_fields":[{"field_name":"foo","type":"string"},{"field_name":"bar","type":"number"},{"field_name":"baz","type":"boolean"}]}`
 
 	b, err := json.Marshal(fields)
 	if err != nil {
@@ -44,18 +45,26 @@ func Test_NodeFieldsMarshall(t *testing.T) {
 }
 
 func Test_GraphAdd(t *testing.T) {
-	const expected = `{"nodes":[{"arc__bar":"baz","arc__foo":0.2,"bar":1,"foo":"bar"}],"edges":[{"bar":1,"foo":"bar"}]}`
+	const expected = `{"nodes":[{"arc__bar":"baz","arc__foo":0.2,"bar":1,"foo":"bar"}],"edges":[{"bar":1,"baz":true,"foo":"bar"}]}`
 
 	g := nodegraph.Graph{Spec: fields}
 	assert.Nil(t,
 		g.AddEdge(map[string]interface{}{
 			"foo": "bar",
 			"bar": 1,
+			"baz": true,
 		}))
 	assert.Equal(t, nodegraph.ErrInvalidGraphItem,
 		g.AddEdge(map[string]interface{}{
 			"foo": "bar",
 			"bar": "1", // The spec defines bar as a number
+			"baz": true,
+		}))
+	assert.Equal(t, nodegraph.ErrInvalidGraphItem,
+		g.AddEdge(map[string]interface{}{
+			"foo": "bar",
+			"bar": 1,
+			"baz": "true", // The spec defines baz as a boolean
 		}))
 	assert.Nil(t,
 		g.AddNode(map[string]interface{}{